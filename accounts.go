@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// cloudflareAccount is the part of a Cloudflare account we use
+type cloudflareAccount struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// resolvedAccountIDs caches the account resolved for each token, so configuration reloads don't query it again
+var (
+	resolvedAccountIDsMutex sync.Mutex
+	resolvedAccountIDs      = map[string]string{}
+)
+
+// listAccounts returns every account the token has access to
+func listAccounts(ctx context.Context, config Configuration) ([]cloudflareAccount, error) {
+	items, err := listCloudflare(ctx, config, "/accounts", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]cloudflareAccount, 0, len(items))
+	for _, item := range items {
+		var account cloudflareAccount
+		if err := json.Unmarshal(item, &account); err != nil {
+			return nil, fmt.Errorf("invalid Cloudflare account: %w", err)
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+// resolveAccountID returns the only account the token has access to
+func resolveAccountID(config Configuration) (string, error) {
+	resolvedAccountIDsMutex.Lock()
+	defer resolvedAccountIDsMutex.Unlock()
+
+	if id, ok := resolvedAccountIDs[config.AuthToken]; ok {
+		return id, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.RunTimeout)
+	defer cancel()
+
+	accounts, err := listAccounts(ctx, config)
+	if err != nil {
+		return "", fmt.Errorf("the accounts of the token could not be listed: %w", err)
+	}
+	switch len(accounts) {
+	case 0:
+		return "", fmt.Errorf("the token has access to no account")
+	case 1:
+	default:
+		var names []string
+		for _, account := range accounts {
+			names = append(names, fmt.Sprintf("%s (%s)", account.ID, account.Name))
+		}
+		return "", fmt.Errorf("the token has access to several accounts, set it to one of: %s", strings.Join(names, ", "))
+	}
+
+	log.Printf("Using Cloudflare account %s (%s), the only one the token has access to", accounts[0].ID, accounts[0].Name)
+	resolvedAccountIDs[config.AuthToken] = accounts[0].ID
+	return accounts[0].ID, nil
+}
+
+// resolveConfigAccount fills in the account when ACCOUNTID is unset, since most tokens only have access
+// to a single account. It is called at startup rather than by loadConfig, so reloads make no API call.
+func resolveConfigAccount(config *Configuration) error {
+	if config.AccountID != "" {
+		return nil
+	}
+	id, err := resolveAccountID(*config)
+	if err != nil {
+		return &ConfigError{Problems: []string{fmt.Sprintf("ACCOUNTID environment variable is not set and %v", err)}}
+	}
+	config.AccountID = id
+	return nil
+}
+
+// runListAccounts implements the list-accounts subcommand, printing the accounts the token has access to
+func runListAccounts(args []string) {
+	flags := flag.NewFlagSet("list-accounts", flag.ExitOnError)
+	profile := flags.String("profile", "", "Named configuration profile to use")
+	_ = flags.Parse(args)
+
+	initConfigSources(*profile)
+	config, err := loadCommandConfig("ACCOUNTID", "RULEID")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.RunTimeout)
+	defer cancel()
+
+	accounts, err := listAccounts(ctx, config)
+	if err != nil {
+		log.Fatalf("Error listing Cloudflare accounts: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME")
+	for _, account := range accounts {
+		fmt.Fprintf(w, "%s\t%s\n", account.ID, account.Name)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"slices"
+)
+
+// Release information, set at build time with -ldflags "-X main.version=v1.2.3 -X main.commit=<sha>"
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// secretConfigKeys are left out of the configuration fingerprint, only whether they are set counts
+var secretConfigKeys = []string{"AUTH_TOKEN", "AUTH_TOKEN_SECONDARY", "API_TOKEN", "WEBHOOK_SECRET", "CONFIG_BACKEND_TOKEN", "NOTIFICATION_URL", "UNIFI_API_KEY", "UNIFI_PASSWORD", "TAILSCALE_API_KEY", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN"}
+
+// buildInfo identifies the running binary
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// currentBuildInfo returns the release information, falling back to the VCS data Go embeds in local builds
+func currentBuildInfo() buildInfo {
+	info := buildInfo{Version: version, Commit: commit, GoVersion: runtime.Version()}
+	if info.Commit != "" {
+		return info
+	}
+
+	if embedded, ok := debug.ReadBuildInfo(); ok {
+		var revision, modified string
+		for _, setting := range embedded.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value
+			case "vcs.modified":
+				modified = setting.Value
+			}
+		}
+		if len(revision) > 12 {
+			revision = revision[:12]
+		}
+		if revision != "" && modified == "true" {
+			revision += "-dirty"
+		}
+		info.Commit = revision
+	}
+	return info
+}
+
+// String formats the build information for the startup banner
+func (b buildInfo) String() string {
+	if b.Commit == "" {
+		return fmt.Sprintf("%s (%s)", b.Version, b.GoVersion)
+	}
+	return fmt.Sprintf("%s (commit %s, %s)", b.Version, b.Commit, b.GoVersion)
+}
+
+// configFingerprint hashes the effective value of every configuration variable, so deployed instances
+// can be compared with the intended configuration without exposing it
+func configFingerprint() string {
+	hash := sha256.New()
+	for _, key := range configKeys {
+		value := getEnv(key.Name)
+		if value != "" && slices.Contains(secretConfigKeys, key.Name) {
+			value = "set"
+		}
+		fmt.Fprintf(hash, "%s=%q\n", key.Name, value)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// enabledFeatures lists the optional features the configuration turns on
+func enabledFeatures(config Configuration) []string {
+	features := []string{}
+	add := func(enabled bool, name string) {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+
+	add(config.NotificationURL != "", "notifications")
+	add(config.APIToken != "", "api")
+	add(config.WebhookSecret != "", "webhook")
+	add(config.AuthTokenFile != "", "token_file")
+	add(config.AuthTokenSecondary != "", "token_failover")
+	add(config.Profile != "", "profile")
+	add(config.PreflightCheck, "preflight")
+	add(config.TraceZone != "", "trace_zone")
+	add(config.UniFiURL != "", "unifi")
+	add(config.MultiWAN, "multi_wan")
+	add(config.TailscaleDevice != "", "tailscale")
+	add(config.IPv6PrefixLength > 0, "ipv6_prefix")
+	add(config.Confirmations > 1, "confirmations")
+	add(config.GroupSpecFile != "", "group_spec")
+	add(config.DryRun, "dry_run")
+	add(config.SimulateIP != "", "simulate_ip")
+	add(config.Serverless, "serverless")
+	add(config.RecordFile != "" || config.ReplayFile != "", "cassette")
+	return features
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// cassetteEntry is one recorded Cloudflare request/response pair, stored as a JSON line
+type cassetteEntry struct {
+	RecordedAt   string `json:"recorded_at"`
+	Method       string `json:"method"`
+	URL          string `json:"url"`
+	RequestBody  string `json:"request_body,omitempty"`
+	Status       int    `json:"status"`
+	ResponseBody string `json:"response_body"`
+}
+
+// recordTransport appends every Cloudflare interaction, redacted, to a cassette file
+type recordTransport struct {
+	next    http.RoundTripper
+	path    string
+	secrets []string
+}
+
+var cassetteWriteMutex sync.Mutex
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		requestBody, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	entry := cassetteEntry{
+		RecordedAt:   time.Now().UTC().Format(time.RFC3339),
+		Method:       req.Method,
+		URL:          redactSecrets(req.URL.String(), t.secrets),
+		RequestBody:  redactSecrets(string(requestBody), t.secrets),
+		Status:       resp.StatusCode,
+		ResponseBody: redactSecrets(string(responseBody), t.secrets),
+	}
+	if err := t.append(entry); err != nil {
+		log.Printf("Failed to record Cloudflare interaction to %s: %v", t.path, err)
+	}
+
+	return resp, nil
+}
+
+func (t *recordTransport) append(entry cassetteEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	cassetteWriteMutex.Lock()
+	defer cassetteWriteMutex.Unlock()
+
+	file, err := os.OpenFile(t.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Printf("Failed to close cassette file: %v", err)
+		}
+	}(file)
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// replayTransport serves recorded interactions back in order instead of calling Cloudflare
+type replayTransport struct {
+	mu      sync.Mutex
+	path    string
+	loaded  bool
+	loadErr error
+	entries []cassetteEntry
+	used    []bool
+}
+
+var (
+	replayersMutex sync.Mutex
+	replayers      = make(map[string]*replayTransport)
+)
+
+// cassetteReplayer returns the shared replayer for a cassette file, so playback
+// position is kept across the per-call HTTP clients
+func cassetteReplayer(path string) *replayTransport {
+	replayersMutex.Lock()
+	defer replayersMutex.Unlock()
+
+	replayer, ok := replayers[path]
+	if !ok {
+		replayer = &replayTransport{path: path}
+		replayers[path] = replayer
+	}
+	return replayer
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.loaded {
+		t.entries, t.loadErr = loadCassette(t.path)
+		t.used = make([]bool, len(t.entries))
+		t.loaded = true
+		if t.loadErr == nil {
+			log.Printf("Replaying %d recorded Cloudflare interactions from %s", len(t.entries), t.path)
+		}
+	}
+	if t.loadErr != nil {
+		return nil, fmt.Errorf("failed to load cassette %s: %w", t.path, t.loadErr)
+	}
+
+	// Match on method and path only, so cassettes replay regardless of the API host
+	for i, entry := range t.entries {
+		if t.used[i] || entry.Method != req.Method {
+			continue
+		}
+		if !cassettePathMatches(entry.URL, req) {
+			continue
+		}
+
+		t.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", entry.Status, http.StatusText(entry.Status)),
+			StatusCode:    entry.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": []string{"application/json"}},
+			Body:          io.NopCloser(bytes.NewBufferString(entry.ResponseBody)),
+			ContentLength: int64(len(entry.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no recorded interaction left for %s %s", req.Method, req.URL.Path)
+}
+
+func cassettePathMatches(recordedURL string, req *http.Request) bool {
+	recorded, err := http.NewRequest(req.Method, recordedURL, nil)
+	if err != nil {
+		return false
+	}
+	return recorded.URL.Path == req.URL.Path
+}
+
+// loadCassette reads a JSON lines cassette file
+func loadCassette(path string) ([]cassetteEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Printf("Failed to close cassette file: %v", err)
+		}
+	}(file)
+
+	var entries []cassetteEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var entry cassetteEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// apiRateLimiter is a blocking token bucket: callers wait for their turn instead of being rejected
+type apiRateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// cloudflareLimiter is shared by every Cloudflare API call of the process, whatever group or command makes it
+var (
+	cloudflareLimiterMutex sync.Mutex
+	cloudflareLimiter      *apiRateLimiter
+)
+
+// cloudflareRateLimiter returns the shared limiter, replacing it when the configured rate changes.
+// It returns nil when limiting is disabled.
+func cloudflareRateLimiter(config Configuration) *apiRateLimiter {
+	if config.CloudflareRateLimit <= 0 {
+		return nil
+	}
+	burst := float64(max(config.CloudflareRateBurst, 1))
+
+	cloudflareLimiterMutex.Lock()
+	defer cloudflareLimiterMutex.Unlock()
+
+	if cloudflareLimiter == nil || cloudflareLimiter.rate != config.CloudflareRateLimit || cloudflareLimiter.burst != burst {
+		cloudflareLimiter = &apiRateLimiter{rate: config.CloudflareRateLimit, burst: burst, tokens: burst, last: time.Now()}
+	}
+	return cloudflareLimiter
+}
+
+// wait reserves a token, blocking until it is available or the context is done
+func (l *apiRateLimiter) wait(ctx context.Context) (time.Duration, error) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return 0, nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return delay, nil
+	case <-ctx.Done():
+		// Give the reservation back for the callers queued behind us
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return 0, ctx.Err()
+	}
+}
+
+// rateLimitTransport delays Cloudflare API requests to stay under the account-wide limit
+type rateLimitTransport struct {
+	next    http.RoundTripper
+	limiter *apiRateLimiter
+	config  Configuration
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	delay, err := t.limiter.wait(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	if delay > 0 {
+		debugf(t.config, "Waited %s for the Cloudflare API rate limit", delay.Round(time.Millisecond))
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+// cloudflarePageSize is the number of items requested per page of Cloudflare list endpoints
+const cloudflarePageSize = 50
+
+// cloudflareMaxPages guards against endpoints that keep returning a next page
+const cloudflareMaxPages = 1000
+
+// cloudflareResultInfo is the pagination part of a Cloudflare list response, by page number or by cursor
+type cloudflareResultInfo struct {
+	Page       int    `json:"page"`
+	PerPage    int    `json:"per_page"`
+	Count      int    `json:"count"`
+	TotalCount int    `json:"total_count"`
+	TotalPages int    `json:"total_pages"`
+	Cursor     string `json:"cursor"`
+	Cursors    struct {
+		After string `json:"after"`
+	} `json:"cursors"`
+}
+
+// listCloudflare returns every item of a paginated Cloudflare list endpoint, such as the Access Groups
+// of an account, following cursors or page numbers so nothing beyond the first page is missed
+func listCloudflare(ctx context.Context, config Configuration, path string, query url.Values) ([]json.RawMessage, error) {
+	client := newCloudflareClient(config)
+
+	var items []json.RawMessage
+	page, cursor := 1, ""
+	for range cloudflareMaxPages {
+		pageQuery := url.Values{}
+		for key, values := range query {
+			pageQuery[key] = values
+		}
+		pageQuery.Set("per_page", strconv.Itoa(cloudflarePageSize))
+		if cursor != "" {
+			pageQuery.Set("cursor", cursor)
+		} else {
+			pageQuery.Set("page", strconv.Itoa(page))
+		}
+
+		body, err := getCloudflare(ctx, client, config, path+"?"+pageQuery.Encode())
+		if err != nil {
+			return nil, err
+		}
+		var response struct {
+			Result     []json.RawMessage    `json:"result"`
+			ResultInfo cloudflareResultInfo `json:"result_info"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, fmt.Errorf("invalid Cloudflare list response: %w", err)
+		}
+		items = append(items, response.Result...)
+
+		info := response.ResultInfo
+		next := info.Cursor
+		if next == "" {
+			next = info.Cursors.After
+		}
+		switch {
+		case len(response.Result) == 0:
+			return items, nil
+		case next != "" && next != cursor:
+			cursor = next
+		case cursor != "":
+			return items, nil
+		case page < info.TotalPages, info.TotalPages == 0 && len(response.Result) == cloudflarePageSize:
+			// Without total_pages a full page may be followed by more
+			page++
+		default:
+			return items, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s returned more than %d pages", path, cloudflareMaxPages)
+}
+
+// getCloudflare performs an authenticated GET against the Cloudflare API
+func getCloudflare(ctx context.Context, client *http.Client, config Configuration, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", config.APIBaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+config.AuthToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Printf("Failed to close response body: %v", err)
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{
+			Message:    fmt.Sprintf("failed to get %s: %s, status: %d", path, string(body), resp.StatusCode),
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+		}
+	}
+	return body, nil
+}
+
+// runListGroups implements the list-groups subcommand, printing the Access Groups of the account to find RULEID
+func runListGroups(args []string) {
+	flags := flag.NewFlagSet("list-groups", flag.ExitOnError)
+	profile := flags.String("profile", "", "Named configuration profile to use")
+	_ = flags.Parse(args)
+
+	initConfigSources(*profile)
+	config, err := loadCommandConfig("RULEID")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.RunTimeout)
+	defer cancel()
+
+	items, err := listCloudflare(ctx, config, fmt.Sprintf("/accounts/%s/access/groups", config.AccountID), nil)
+	if err != nil {
+		log.Fatalf("Error listing Cloudflare Access Groups: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME")
+	for _, item := range items {
+		var group struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(item, &group); err != nil {
+			log.Fatalf("Invalid Access Group: %v", err)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", group.ID, group.Name)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
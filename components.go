@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Components whose status is reported by /health and /ready
+const (
+	ComponentIPDetection   = "ip_detection"
+	ComponentCloudflareAPI = "cloudflare_api"
+	ComponentNotifications = "notifications"
+	ComponentScheduler     = "scheduler"
+)
+
+// Statuses of a component, from best to worst; unknown and disabled components don't affect the overall status
+const (
+	ComponentOK       = "ok"
+	ComponentDegraded = "degraded"
+	ComponentFailing  = "failing"
+	ComponentUnknown  = "unknown"
+	ComponentDisabled = "disabled"
+)
+
+// components lists the components in the order they are reported
+var components = []string{ComponentIPDetection, ComponentCloudflareAPI, ComponentNotifications, ComponentScheduler}
+
+// componentHealth is the status of a component as reported by /ready
+type componentHealth struct {
+	Status    string    `json:"status"`
+	Since     time.Time `json:"since,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// observedComponents holds the outcome of the last operation of the components that report one
+var (
+	observedComponentsMutex sync.Mutex
+	observedComponents      = map[string]componentHealth{}
+)
+
+// scheduler is the cron scheduler of the checks, nil until it started
+var scheduler atomic.Pointer[cron.Cron]
+
+// checkStartedAt is when the running check started, nil while none runs
+var checkStartedAt atomic.Pointer[time.Time]
+
+// recordComponent records the outcome of an operation of a component, nil meaning success
+func recordComponent(component string, err error) {
+	observedComponentsMutex.Lock()
+	defer observedComponentsMutex.Unlock()
+
+	health := componentHealth{Status: ComponentOK}
+	if err != nil {
+		health = componentHealth{Status: ComponentFailing, LastError: err.Error()}
+	}
+	health.Since = time.Now().Truncate(time.Second)
+	if previous, ok := observedComponents[component]; ok && previous.Status == health.Status {
+		health.Since = previous.Since
+	}
+	observedComponents[component] = health
+}
+
+// componentsHealth returns the current status of every component
+func componentsHealth(config Configuration) map[string]componentHealth {
+	observedComponentsMutex.Lock()
+	health := make(map[string]componentHealth, len(components))
+	for _, component := range components {
+		health[component] = componentHealth{Status: ComponentUnknown}
+		if observed, ok := observedComponents[component]; ok {
+			health[component] = observed
+		}
+	}
+	observedComponentsMutex.Unlock()
+
+	// Detection still works with providers down, but has fewer to fall back on
+	if detection := health[ComponentIPDetection]; detection.Status == ComponentOK {
+		var open []string
+		for provider, h := range providerHealthSnapshot() {
+			if h.breakerState() == BreakerOpen {
+				open = append(open, provider)
+			}
+		}
+		if len(open) > 0 {
+			detection.Status = ComponentDegraded
+			detection.LastError = fmt.Sprintf("circuit breaker open for %s", strings.Join(open, ", "))
+			health[ComponentIPDetection] = detection
+		}
+	}
+
+	if config.NotificationURL == "" {
+		health[ComponentNotifications] = componentHealth{Status: ComponentDisabled}
+	}
+	health[ComponentScheduler] = schedulerHealth(config)
+
+	for component, h := range health {
+		h.Since = displayTime(config, h.Since)
+		health[component] = h
+	}
+	return health
+}
+
+// schedulerHealth reports a scheduler that stopped firing or a check that runs far beyond RUN_TIMEOUT
+func schedulerHealth(config Configuration) componentHealth {
+	c := scheduler.Load()
+	if c == nil {
+		return componentHealth{Status: ComponentUnknown}
+	}
+
+	entries := c.Entries()
+	if len(entries) == 0 {
+		return componentHealth{Status: ComponentFailing, LastError: "no check is scheduled"}
+	}
+	for _, entry := range entries {
+		if time.Since(entry.Next) > time.Minute {
+			return componentHealth{Status: ComponentFailing, LastError: fmt.Sprintf("the check due at %s did not start", displayTime(config, entry.Next).Format(time.RFC3339))}
+		}
+	}
+	if started := checkStartedAt.Load(); started != nil && time.Since(*started) > 2*config.RunTimeout {
+		return componentHealth{Status: ComponentDegraded, Since: *started, LastError: fmt.Sprintf("a check has been running since %s", displayTime(config, *started).Format(time.RFC3339))}
+	}
+	return componentHealth{Status: ComponentOK}
+}
+
+// overallStatus rolls the components up into the worst status among them
+func overallStatus(health map[string]componentHealth) string {
+	overall := ComponentOK
+	for _, h := range health {
+		switch {
+		case h.Status == ComponentFailing:
+			overall = ComponentFailing
+		case h.Status == ComponentDegraded && overall == ComponentOK:
+			overall = ComponentDegraded
+		}
+	}
+	return overall
+}
+
+// componentTransport records the outcome of Cloudflare API calls as the health of the API
+type componentTransport struct {
+	next http.RoundTripper
+}
+
+func (t *componentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		recordComponent(ComponentCloudflareAPI, err)
+	case resp.StatusCode >= 500 || isAuthError(resp.StatusCode) || resp.StatusCode == http.StatusTooManyRequests:
+		recordComponent(ComponentCloudflareAPI, fmt.Errorf("%s %s: status %d", req.Method, req.URL.Path, resp.StatusCode))
+	default:
+		recordComponent(ComponentCloudflareAPI, nil)
+	}
+	return resp, err
+}
@@ -0,0 +1,810 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // the container image has no zoneinfo, embed it for DISPLAY_TZ
+
+	"github.com/joho/godotenv"
+	"github.com/robfig/cron/v3"
+)
+
+// Configuration holds environment variables
+type Configuration struct {
+	AccountID              string
+	RuleID                 string // the Access Group being worked on, the first of RuleIDs by default
+	RuleIDs                []string
+	TargetParallelism      int
+	CronSchedule           string
+	AuthToken              string
+	AuthTokenFile          string
+	AuthTokenSecondary     string
+	NotificationURL        string
+	NotificationIdentifier string
+	TestNotification       bool
+	ProviderRetries        int
+	ProviderRetryBackoff   time.Duration
+	ProviderPasses         int
+	ProviderPassBackoff    time.Duration
+	BreakerThreshold       int
+	BreakerCooldown        time.Duration
+	RunTimeout             time.Duration
+	ShutdownTimeout        time.Duration
+	ProviderTimeout        time.Duration
+	CloudflareTimeout      time.Duration
+	CloudflareRateLimit    float64
+	CloudflareRateBurst    int
+	NotificationTimeout    time.Duration
+	HTTPDebug              bool
+	TraceZone              string
+	PreflightCheck         bool
+	PreflightRetryInterval time.Duration
+	DryRun                 bool
+	SimulateIP             string
+	PushedIP               string // sent by the monitored site with a serverless invocation
+	Serverless             bool
+	APIBaseURL             string
+	RecordFile             string
+	ReplayFile             string
+	Profile                string
+	HTTPRateLimit          float64
+	HTTPRateBurst          int
+	HTTPMaxBodyBytes       int64
+	HTTPTrustProxyHeaders  bool
+	CORSAllowedOrigins     []string
+	CORSAllowedMethods     []string
+	CORSAllowedHeaders     []string
+	APIToken               string
+	WebhookSecret          string
+	StateFile              string
+	PersistCounters        bool // STATE_FILE was set explicitly rather than defaulted
+	LogLevel               string
+	LogFormat              string
+	HeartbeatInterval      time.Duration
+	NotifyGroupDiff        bool
+	NotifyTimings          bool
+	NotifyParams           map[string]string
+	NotifyWarningParams    map[string]string
+	NotifyErrorParams      map[string]string
+	UniFiURL               string
+	UniFiAPIKey            string
+	UniFiUsername          string
+	UniFiPassword          string
+	UniFiSite              string
+	UniFiSkipVerify        bool
+	MultiWAN               bool
+	WANInterfaces          []string
+	TailscaleAPIURL        string
+	TailscaleAPIKey        string
+	TailscaleTailnet       string
+	TailscaleDevice        string
+	IPv6PrefixLength       int
+	IPv6PrefixInterface    string
+	Confirmations          int
+	ConfirmBy              string
+	FlapThreshold          int
+	FlapWindow             time.Duration
+	VerifyInterval         time.Duration
+	GroupSpecFile          string
+	NotificationMaxLength  map[string]int
+	Language               string
+	DisplayLocation        *time.Location
+	Fingerprint            string
+
+	// notifications collects the notifications of the targets of a run, nil outside of one
+	notifications *notificationBatch
+}
+
+// ConfigError lists every problem found while validating the configuration
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid configuration:\n  - %s", strings.Join(e.Problems, "\n  - "))
+}
+
+// Is makes configuration problems match ErrValidation
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// configValidator collects configuration problems instead of stopping at the first one
+type configValidator struct {
+	problems []string
+	optional []string // mandatory keys the current command can do without
+}
+
+func (v *configValidator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+// required reads a mandatory configuration key
+func (v *configValidator) required(name string) string {
+	value := getEnv(name)
+	if value == "" && !slices.Contains(v.optional, name) {
+		v.addf("%s environment variable is not set", name)
+	}
+	return value
+}
+
+// int reads an optional non-negative integer configuration key
+func (v *configValidator) int(name string, def int) int {
+	n, err := getEnvInt(name, def)
+	if err != nil {
+		v.problems = append(v.problems, err.Error())
+		return def
+	}
+	return n
+}
+
+// rate reads an optional non-negative requests per second configuration key
+func (v *configValidator) rate(name string, def float64) float64 {
+	value := getEnv(name)
+	if value == "" {
+		return def
+	}
+
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 {
+		v.addf("%s must be a non-negative number of requests per second, got %q", name, value)
+		return def
+	}
+	return rate
+}
+
+// duration reads an optional non-negative duration configuration key
+func (v *configValidator) duration(name string, def time.Duration) time.Duration {
+	d, err := getEnvDuration(name, def)
+	if err != nil {
+		v.problems = append(v.problems, err.Error())
+		return def
+	}
+	return d
+}
+
+// url checks that an optional configuration value is an absolute URL with one of the given schemes
+func (v *configValidator) url(name, value string, schemes ...string) {
+	if value == "" {
+		return
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" {
+		v.addf("%s must be a valid URL, got %q", name, value)
+		return
+	}
+	if len(schemes) > 0 && !slices.Contains(schemes, parsed.Scheme) {
+		v.addf("%s must use one of the schemes %s, got %q", name, strings.Join(schemes, ", "), parsed.Scheme)
+	}
+}
+
+func (v *configValidator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ConfigError{Problems: v.problems}
+}
+
+// loadConfig reads and validates the configuration from the environment, reporting all problems at once.
+// Commands that can run without some mandatory keys, such as list-groups without RULEID, pass them as optional.
+func loadConfig(optional ...string) (Configuration, error) {
+	v := &configValidator{optional: optional}
+
+	// Resolved from the token below when unset
+	accountID := getEnv("ACCOUNTID")
+	// One or more comma-separated Access Groups, all receiving the same IP
+	ruleIDs := splitList(v.required("RULEID"))
+	ruleID := ""
+	if len(ruleIDs) > 0 {
+		ruleID = ruleIDs[0]
+	}
+	for i, id := range ruleIDs {
+		if slices.Contains(ruleIDs[:i], id) {
+			v.addf("RULEID lists %s more than once", id)
+		}
+	}
+	targetParallelism := v.int("TARGET_PARALLELISM", 4)
+	if targetParallelism < 1 {
+		v.addf("TARGET_PARALLELISM must be at least 1, got %d", targetParallelism)
+	}
+	cronSchedule := v.required("CRON")
+	if cronSchedule != "" {
+		if err := validateCronSchedule(cronSchedule); err != nil {
+			v.problems = append(v.problems, err.Error())
+		}
+	}
+
+	// The token may live in a file instead, rotated without a restart (optional)
+	authTokenFile := getEnv("AUTH_TOKEN_FILE")
+	var authToken string
+	if authTokenFile != "" {
+		var err error
+		authToken, err = readAuthTokenFile(authTokenFile)
+		if err != nil {
+			v.addf("AUTH_TOKEN_FILE: %v", err)
+		}
+	} else {
+		authToken = v.required("AUTH_TOKEN")
+	}
+	// A token rotated through the API outlives reloads of the configuration
+	if rotated := rotatedAuthToken(); rotated != "" {
+		authToken = rotated
+	}
+
+	// Optional: Token used when Cloudflare rejects AUTH_TOKEN, e.g. once it was revoked or expired
+	authTokenSecondary := getEnv("AUTH_TOKEN_SECONDARY")
+	if authTokenSecondary != "" && authTokenSecondary == authToken {
+		v.addf("AUTH_TOKEN_SECONDARY must differ from AUTH_TOKEN")
+	}
+
+	// Optional: Notification URL (using Shoutrrr URL format)
+	notificationURL := getEnv("NOTIFICATION_URL")
+	v.url("NOTIFICATION_URL", notificationURL)
+
+	// Optional: Notification URL (using Shoutrrr URL format)
+	notificationIdentifier := getEnv("NOTIFICATION_IDENTIFIER")
+
+	// Test notification on startup (optional)
+	testNotification := false
+	if getEnv("TEST_NOTIFICATION") == "true" {
+		testNotification = true
+	}
+
+	// Retries against the same IP provider before failing over (optional)
+	providerRetries := v.int("PROVIDER_RETRIES", 1)
+
+	// Initial delay between provider retries, doubled on every attempt (optional)
+	providerRetryBackoff := v.duration("PROVIDER_RETRY_BACKOFF", 500*time.Millisecond)
+
+	// Passes over all providers when every one failed, with a backoff doubled after each (optional)
+	providerPasses := v.int("PROVIDER_PASSES", 1)
+	if providerPasses < 1 {
+		v.addf("PROVIDER_PASSES must be at least 1, got %d", providerPasses)
+	}
+	providerPassBackoff := v.duration("PROVIDER_PASS_BACKOFF", 5*time.Second)
+
+	// Consecutive failures that take a provider out of rotation, and for how long (optional)
+	breakerThreshold := v.int("PROVIDER_BREAKER_THRESHOLD", 3)
+	breakerCooldown := v.duration("PROVIDER_BREAKER_COOLDOWN", 5*time.Minute)
+
+	// Overall deadline for a single check run (optional)
+	runTimeout := v.duration("RUN_TIMEOUT", 90*time.Second)
+	if runTimeout == 0 {
+		v.addf("RUN_TIMEOUT must be greater than zero")
+	}
+
+	// How long a shutdown waits for a running check to finish (optional)
+	shutdownTimeout := v.duration("SHUTDOWN_TIMEOUT", 30*time.Second)
+
+	// Account-wide limit of Cloudflare API calls, Cloudflare allows 1200 per 5 minutes (optional)
+	cloudflareRateLimit := v.rate("CLOUDFLARE_RATE_LIMIT", 3)
+	cloudflareRateBurst := v.int("CLOUDFLARE_RATE_BURST", 10)
+
+	// Per-request timeouts for IP providers, the Cloudflare API and notification sends (optional)
+	providerTimeout := v.duration("PROVIDER_TIMEOUT", 5*time.Second)
+	cloudflareTimeout := v.duration("CLOUDFLARE_TIMEOUT", 30*time.Second)
+	notificationTimeout := v.duration("NOTIFICATION_TIMEOUT", 30*time.Second)
+
+	// Log redacted HTTP request/response traces (optional)
+	httpDebug := getEnv("HTTP_DEBUG") == "true"
+
+	// Cloudflare-proxied hostname whose /cdn-cgi/trace is tried first (optional)
+	traceZone := strings.TrimSuffix(strings.TrimPrefix(getEnv("TRACE_ZONE"), "https://"), "/")
+	if strings.ContainsAny(traceZone, "/: ") {
+		v.addf("TRACE_ZONE must be a hostname, got %q", traceZone)
+	}
+
+	// Verify Cloudflare connectivity before IP detection and retry sooner on failure (optional)
+	preflightCheck := getEnv("PREFLIGHT_CHECK") == "true"
+	preflightRetryInterval := v.duration("PREFLIGHT_RETRY_INTERVAL", time.Minute)
+
+	// Log intended Cloudflare changes without applying them (optional)
+	dryRun := getEnv("DRY_RUN") == "true"
+
+	// Cloudflare API base URL, e.g. to point at the built-in mock server (optional)
+	apiBaseURL := strings.TrimSuffix(getEnv("CF_API_BASE_URL"), "/")
+	if apiBaseURL == "" {
+		apiBaseURL = "https://api.cloudflare.com/client/v4"
+	}
+	v.url("CF_API_BASE_URL", apiBaseURL, "http", "https")
+
+	// Record Cloudflare interactions to a cassette file, or replay them from one (optional)
+	recordFile := getEnv("CF_RECORD_FILE")
+	replayFile := getEnv("CF_REPLAY_FILE")
+	if recordFile != "" && replayFile != "" {
+		v.addf("CF_RECORD_FILE and CF_REPLAY_FILE cannot be used together")
+	}
+
+	// Protection of the HTTP server (optional)
+	httpRateLimit := v.rate("HTTP_RATE_LIMIT", 10)
+	httpRateBurst := v.int("HTTP_RATE_BURST", 20)
+	httpMaxBodyBytes := v.int("HTTP_MAX_BODY_BYTES", 64<<10)
+	httpTrustProxyHeaders := getEnv("HTTP_TRUST_PROXY_HEADERS") == "true"
+
+	// CORS settings for browser dashboards on other origins (optional)
+	corsAllowedOrigins := splitList(getEnv("CORS_ALLOWED_ORIGINS"))
+	for _, origin := range corsAllowedOrigins {
+		if origin != "*" {
+			v.url("CORS_ALLOWED_ORIGINS", origin, "http", "https")
+		}
+	}
+	corsAllowedMethods := splitList(getEnv("CORS_ALLOWED_METHODS"))
+	if len(corsAllowedMethods) == 0 {
+		corsAllowedMethods = []string{"GET", "POST", "OPTIONS"}
+	}
+	corsAllowedHeaders := splitList(getEnv("CORS_ALLOWED_HEADERS"))
+	if len(corsAllowedHeaders) == 0 {
+		corsAllowedHeaders = []string{"Authorization", "Content-Type"}
+	}
+
+	// Bearer token protecting the control API; control endpoints are disabled without it (optional)
+	apiToken := getEnv("API_TOKEN")
+
+	// Secret of the Cloudflare notification webhook; the webhook endpoint is disabled without it (optional)
+	webhookSecret := getEnv("WEBHOOK_SECRET")
+
+	// File keeping data between runs, such as the static IPs (optional)
+	stateFile := getEnv("STATE_FILE")
+	// Counters are written on every run, so only to a file the user chose
+	persistCounters := stateFile != ""
+	if stateFile == "" {
+		stateFile = "state.json"
+	}
+
+	// Logging verbosity (optional)
+	logLevel := strings.ToLower(getEnv("LOG_LEVEL"))
+	switch logLevel {
+	case "":
+		logLevel = "info"
+	case "debug", "info":
+	default:
+		v.addf("LOG_LEVEL must be \"debug\" or \"info\", got %q", logLevel)
+	}
+
+	// Log output format (optional), pretty falls back to plain when the log isn't written to a terminal
+	logFormat := strings.ToLower(getEnv("LOG_FORMAT"))
+	switch logFormat {
+	case "":
+		logFormat = "plain"
+	case "plain", "pretty", "json":
+	default:
+		v.addf("LOG_FORMAT must be \"plain\", \"pretty\" or \"json\", got %q", logFormat)
+	}
+
+	// Interval of the heartbeat log line, 0 disables it (optional)
+	heartbeatInterval := v.duration("HEARTBEAT_INTERVAL", 0)
+
+	// Include the group JSON diff in update notifications (optional)
+	notifyGroupDiff := getEnv("NOTIFY_GROUP_DIFF") == "true"
+
+	// Include the winning provider and the run timings in update notifications (optional)
+	notifyTimings := getEnv("NOTIFY_TIMINGS") == "true"
+
+	// UniFi console or Network controller reporting the gateway's WAN address (optional)
+	unifiURL := strings.TrimSuffix(getEnv("UNIFI_URL"), "/")
+	unifiAPIKey := getEnv("UNIFI_API_KEY")
+	unifiUsername := getEnv("UNIFI_USERNAME")
+	unifiPassword := getEnv("UNIFI_PASSWORD")
+	unifiSite := getEnv("UNIFI_SITE")
+	if unifiSite == "" {
+		unifiSite = "default"
+	}
+	if unifiURL != "" {
+		v.url("UNIFI_URL", unifiURL, "http", "https")
+		if unifiAPIKey == "" && (unifiUsername == "" || unifiPassword == "") {
+			v.addf("UNIFI_URL requires UNIFI_API_KEY or UNIFI_USERNAME and UNIFI_PASSWORD")
+		}
+	}
+	unifiInsecureSkipVerify := getEnv("UNIFI_INSECURE_SKIP_VERIFY") == "true"
+
+	// Publish the IPs of all uplinks of a multi-WAN site (optional)
+	multiWAN := getEnv("MULTI_WAN") == "true"
+	wanInterfaces := splitList(getEnv("WAN_INTERFACES"))
+	if multiWAN && unifiURL == "" && len(wanInterfaces) == 0 {
+		v.addf("MULTI_WAN requires UNIFI_URL or WAN_INTERFACES to detect the uplinks")
+	}
+
+	// Track the public IP of a remote tailnet device instead of our own (optional)
+	tailscaleDevice := getEnv("TAILSCALE_DEVICE")
+	var tailscaleAPIKey string
+	tailscaleTailnet := getEnv("TAILSCALE_TAILNET")
+	if tailscaleTailnet == "" {
+		tailscaleTailnet = "-"
+	}
+	tailscaleAPIURL := strings.TrimSuffix(getEnv("TAILSCALE_API_URL"), "/")
+	if tailscaleAPIURL == "" {
+		tailscaleAPIURL = "https://api.tailscale.com"
+	}
+	if tailscaleDevice != "" {
+		tailscaleAPIKey = v.required("TAILSCALE_API_KEY")
+		v.url("TAILSCALE_API_URL", tailscaleAPIURL, "http", "https")
+		if multiWAN {
+			v.addf("TAILSCALE_DEVICE cannot be used together with MULTI_WAN")
+		}
+	}
+
+	// Observations of a new IP required before it is applied (optional)
+	confirmations := v.int("CONFIRMATIONS", 1)
+	confirmBy := strings.ToLower(getEnv("CONFIRM_BY"))
+	switch confirmBy {
+	case "":
+		confirmBy = ConfirmByChecks
+	case ConfirmByChecks, ConfirmByProviders:
+	default:
+		v.addf("CONFIRM_BY must be %s or %s, got %q", ConfirmByChecks, ConfirmByProviders, confirmBy)
+	}
+	if confirmations > 1 && multiWAN {
+		v.addf("CONFIRMATIONS cannot be used together with MULTI_WAN")
+	}
+
+	// Declare the full content of the Access Group, reconciled on every run (optional)
+	groupSpecFile := getEnv("GROUP_SPEC_FILE")
+	if groupSpecFile != "" {
+		template, err := loadGroupSpec(groupSpecFile)
+		switch {
+		case err != nil:
+			v.addf("GROUP_SPEC_FILE: %v", err)
+		case !strings.Contains(template, dynamicIPPlaceholder):
+			log.Printf("Warning: group spec %s has no %s entry, the detected IP won't be published", groupSpecFile, dynamicIPPlaceholder)
+		}
+		if multiWAN {
+			v.addf("GROUP_SPEC_FILE cannot be used together with MULTI_WAN")
+		}
+	}
+
+	// Changes of the detected IP within a window that count as flapping (optional)
+	flapThreshold := v.int("FLAP_THRESHOLD", 0)
+	flapWindow := v.duration("FLAP_WINDOW", time.Hour)
+	switch {
+	case flapThreshold > 0 && flapWindow == 0:
+		v.addf("FLAP_WINDOW must be greater than zero")
+	case flapThreshold > 0 && multiWAN:
+		v.addf("FLAP_THRESHOLD cannot be used together with MULTI_WAN")
+	}
+
+	// Skip the group lookup while the detected IP is the one verified within this interval (optional)
+	verifyInterval := v.duration("VERIFY_INTERVAL", 0)
+	if verifyInterval > 0 && multiWAN {
+		v.addf("VERIFY_INTERVAL cannot be used together with MULTI_WAN")
+	}
+
+	// Publish the delegated IPv6 prefix instead of a single address (optional)
+	ipv6PrefixLength := v.int("IPV6_PREFIX_LENGTH", 0)
+	ipv6PrefixInterface := getEnv("IPV6_PREFIX_INTERFACE")
+	switch {
+	case ipv6PrefixLength > 128:
+		v.addf("IPV6_PREFIX_LENGTH must be between 1 and 128, got %d", ipv6PrefixLength)
+	case ipv6PrefixLength == 0 && ipv6PrefixInterface != "":
+		v.addf("IPV6_PREFIX_INTERFACE requires IPV6_PREFIX_LENGTH")
+	case ipv6PrefixLength > 0 && multiWAN:
+		v.addf("IPV6_PREFIX_LENGTH cannot be used together with MULTI_WAN")
+	}
+
+	// A single provider can't confirm its own answer
+	if confirmations > 1 && confirmBy == ConfirmByProviders && (tailscaleDevice != "" || ipv6PrefixInterface != "") {
+		v.addf("CONFIRM_BY=providers needs several IP providers, use CONFIRM_BY=checks with TAILSCALE_DEVICE or IPV6_PREFIX_INTERFACE")
+	}
+
+	// Maximum notification length, per service (optional)
+	notificationMaxLength, err := parseNotificationMaxLength(getEnv("NOTIFICATION_MAX_LENGTH"))
+	if err != nil {
+		v.addf("NOTIFICATION_MAX_LENGTH: %v", err)
+	}
+
+	// shoutrrr parameters such as the title or priority, for every message, warnings and failures (optional)
+	notifyParams, err := parseNotificationParams(getEnv("NOTIFICATION_PARAMS"))
+	if err != nil {
+		v.addf("NOTIFICATION_PARAMS: %v", err)
+	}
+	notifyWarningParams, err := parseNotificationParams(getEnv("NOTIFICATION_WARNING_PARAMS"))
+	if err != nil {
+		v.addf("NOTIFICATION_WARNING_PARAMS: %v", err)
+	}
+	notifyErrorParams, err := parseNotificationParams(getEnv("NOTIFICATION_ERROR_PARAMS"))
+	if err != nil {
+		v.addf("NOTIFICATION_ERROR_PARAMS: %v", err)
+	}
+
+	// Language of the notifications (optional); LANGUAGE may also hold the system locale, so an
+	// unsupported value falls back to English rather than failing
+	language, supported := normalizeLanguage(getEnv("LANGUAGE"))
+	if !supported {
+		log.Printf("No translations for LANGUAGE %q, notifications will be in English", language)
+		language = "en"
+	}
+
+	// Time zone of human-facing timestamps, instead of the container's (optional)
+	var displayLocation *time.Location
+	if value := getEnv("DISPLAY_TZ"); value != "" {
+		displayLocation, err = time.LoadLocation(value)
+		if err != nil {
+			v.addf("DISPLAY_TZ must be a time zone name such as Europe/Athens, got %q", value)
+		}
+	}
+
+	if err := v.err(); err != nil {
+		return Configuration{}, err
+	}
+
+	config := Configuration{
+		AccountID:              accountID,
+		RuleID:                 ruleID,
+		RuleIDs:                ruleIDs,
+		TargetParallelism:      targetParallelism,
+		CronSchedule:           cronSchedule,
+		AuthToken:              authToken,
+		AuthTokenFile:          authTokenFile,
+		AuthTokenSecondary:     authTokenSecondary,
+		NotificationURL:        notificationURL,
+		NotificationIdentifier: notificationIdentifier,
+		TestNotification:       testNotification,
+		ProviderRetries:        providerRetries,
+		ProviderRetryBackoff:   providerRetryBackoff,
+		ProviderPasses:         providerPasses,
+		ProviderPassBackoff:    providerPassBackoff,
+		BreakerThreshold:       breakerThreshold,
+		BreakerCooldown:        breakerCooldown,
+		RunTimeout:             runTimeout,
+		ShutdownTimeout:        shutdownTimeout,
+		ProviderTimeout:        providerTimeout,
+		CloudflareTimeout:      cloudflareTimeout,
+		CloudflareRateLimit:    cloudflareRateLimit,
+		CloudflareRateBurst:    cloudflareRateBurst,
+		NotificationTimeout:    notificationTimeout,
+		HTTPDebug:              httpDebug,
+		TraceZone:              traceZone,
+		PreflightCheck:         preflightCheck,
+		PreflightRetryInterval: preflightRetryInterval,
+		DryRun:                 dryRun,
+		APIBaseURL:             apiBaseURL,
+		RecordFile:             recordFile,
+		ReplayFile:             replayFile,
+		Profile:                activeProfile,
+		HTTPRateLimit:          httpRateLimit,
+		HTTPRateBurst:          httpRateBurst,
+		HTTPMaxBodyBytes:       int64(httpMaxBodyBytes),
+		HTTPTrustProxyHeaders:  httpTrustProxyHeaders,
+		CORSAllowedOrigins:     corsAllowedOrigins,
+		CORSAllowedMethods:     corsAllowedMethods,
+		CORSAllowedHeaders:     corsAllowedHeaders,
+		APIToken:               apiToken,
+		WebhookSecret:          webhookSecret,
+		StateFile:              stateFile,
+		PersistCounters:        persistCounters,
+		LogLevel:               logLevel,
+		LogFormat:              logFormat,
+		HeartbeatInterval:      heartbeatInterval,
+		NotifyGroupDiff:        notifyGroupDiff,
+		NotifyTimings:          notifyTimings,
+		NotifyParams:           notifyParams,
+		NotifyWarningParams:    notifyWarningParams,
+		NotifyErrorParams:      notifyErrorParams,
+		UniFiURL:               unifiURL,
+		UniFiAPIKey:            unifiAPIKey,
+		UniFiUsername:          unifiUsername,
+		UniFiPassword:          unifiPassword,
+		UniFiSite:              unifiSite,
+		UniFiSkipVerify:        unifiInsecureSkipVerify,
+		MultiWAN:               multiWAN,
+		WANInterfaces:          wanInterfaces,
+		TailscaleAPIURL:        tailscaleAPIURL,
+		TailscaleAPIKey:        tailscaleAPIKey,
+		TailscaleTailnet:       tailscaleTailnet,
+		TailscaleDevice:        tailscaleDevice,
+		IPv6PrefixLength:       ipv6PrefixLength,
+		IPv6PrefixInterface:    ipv6PrefixInterface,
+		Confirmations:          confirmations,
+		ConfirmBy:              confirmBy,
+		FlapThreshold:          flapThreshold,
+		FlapWindow:             flapWindow,
+		VerifyInterval:         verifyInterval,
+		GroupSpecFile:          groupSpecFile,
+		NotificationMaxLength:  notificationMaxLength,
+		Language:               language,
+		DisplayLocation:        displayLocation,
+		Fingerprint:            configFingerprint(),
+	}
+	return config, nil
+}
+
+// loadCommandConfig loads the configuration of a one-shot command, resolving the account when ACCOUNTID
+// is unset and the command needs one. One-shot commands never schedule checks, so CRON is optional.
+func loadCommandConfig(optional ...string) (Configuration, error) {
+	config, err := loadConfig(append(optional, "CRON")...)
+	if err != nil || slices.Contains(optional, "ACCOUNTID") {
+		return config, err
+	}
+	return config, resolveConfigAccount(&config)
+}
+
+// splitList splits a comma-separated configuration value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// validateCronSchedule parses a CRON expression and, on failure, explains the expected format with examples
+func validateCronSchedule(expr string) error {
+	_, err := cron.ParseStandard(expr)
+	if err == nil {
+		return nil
+	}
+
+	hint := ""
+	fields := strings.Fields(expr)
+	switch {
+	case strings.HasPrefix(expr, "@"):
+		hint = "\n    supported descriptors are @yearly, @monthly, @weekly, @daily, @hourly and @every <duration>"
+	case len(fields) == 6:
+		hint = "\n    a seconds field is not supported, remove the first field"
+	}
+
+	return fmt.Errorf(`CRON %q is not a valid schedule: %w%s
+    expected 5 space-separated fields: minute hour day-of-month month day-of-week
+    examples: "*/5 * * * *" (every 5 minutes), "0 * * * *" (every hour), "0 0 * * *" (every day at midnight), "@hourly", "@every 10m"`,
+		expr, err, hint)
+}
+
+// loadEnvFiles loads ./.env, or the comma-separated files listed in ENV_FILE where later
+// files override earlier ones. Variables already set in the environment always win.
+func loadEnvFiles() {
+	envFile := os.Getenv("ENV_FILE")
+	if envFile == "" {
+		if err := godotenv.Load(); err != nil {
+			log.Println("No .env file found or error loading it. Using environment variables directly.")
+		} else {
+			log.Println("Successfully loaded .env file")
+		}
+		return
+	}
+
+	merged := make(map[string]string)
+	for _, file := range splitList(envFile) {
+		values, err := godotenv.Read(file)
+		if err != nil {
+			log.Fatalf("Failed to load env file %s: %v", file, err)
+		}
+		for key, value := range values {
+			merged[key] = value
+		}
+		log.Printf("Successfully loaded env file %s", file)
+	}
+
+	for key, value := range merged {
+		if _, exists := os.LookupEnv(key); exists {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			log.Fatalf("Failed to set %s from env file: %v", key, err)
+		}
+	}
+}
+
+// initConfigSources loads the env files, selects the profile (falling back to PROFILE) and
+// fetches the remote configuration backend if one is configured
+func initConfigSources(profile string) *configBackend {
+	loadEnvFiles()
+
+	activeProfile = profile
+	if activeProfile == "" {
+		activeProfile = os.Getenv("PROFILE")
+	}
+	if activeProfile != "" {
+		log.Printf("Using configuration profile: %s", activeProfile)
+	}
+
+	backend, err := loadConfigBackendSettings()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if backend != nil {
+		if err := loadConfigBackend(backend); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	return backend
+}
+
+// activeProfile is the named configuration profile selected with --profile or PROFILE
+var activeProfile string
+
+// profileEnvName returns the profile-specific variant of a configuration key,
+// e.g. PROFILE_HOME_ACCOUNTID for ACCOUNTID in the "home" profile
+func profileEnvName(profile, name string) string {
+	profile = strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(profile))
+	return "PROFILE_" + profile + "_" + name
+}
+
+// secretsDir is where Docker Swarm/Compose mounts secrets
+var secretsDir = "/run/secrets"
+
+// getEnv reads a configuration key, preferring the active profile's value over the shared one.
+// Values from a Consul/etcd backend take precedence over the environment, and when the variable
+// is unset everywhere, a Docker secret named after the lowercased key is used instead.
+func getEnv(name string) string {
+	names := []string{name}
+	if activeProfile != "" {
+		names = []string{profileEnvName(activeProfile, name), name}
+	}
+
+	for _, key := range names {
+		if value, ok := getBackendValue(key); ok {
+			return value
+		}
+	}
+
+	for _, key := range names {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+	}
+
+	for _, key := range names {
+		if value, ok := readDockerSecret(key); ok {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// readDockerSecret reads /run/secrets/<key> (lowercased) if it exists
+func readDockerSecret(key string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(secretsDir, strings.ToLower(key)))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Failed to read Docker secret for %s: %v", key, err)
+		}
+		return "", false
+	}
+
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// getEnvInt reads a non-negative integer environment variable, falling back to def when unset
+func getEnvInt(name string, def int) (int, error) {
+	value := getEnv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", name, value)
+	}
+	return n, nil
+}
+
+// getEnvDuration reads a non-negative duration environment variable, falling back to def when unset
+func getEnvDuration(name string, def time.Duration) (time.Duration, error) {
+	value := getEnv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s must be a valid duration (e.g. 30s, 5m), got %q", name, value)
+	}
+	return d, nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// configBackend is a remote key/value store (Consul, etcd or AWS SSM Parameter Store) holding configuration keys under a prefix
+type configBackend struct {
+	Kind          string // "consul", "etcd" or "ssm"
+	URL           string
+	Prefix        string
+	Token         string
+	WatchInterval time.Duration
+}
+
+var (
+	backendValuesMutex sync.RWMutex
+	backendValues      map[string]string
+)
+
+// getBackendValue returns a configuration key loaded from the remote backend
+func getBackendValue(name string) (string, bool) {
+	backendValuesMutex.RLock()
+	defer backendValuesMutex.RUnlock()
+
+	value, ok := backendValues[name]
+	return value, ok && value != ""
+}
+
+func setBackendValues(values map[string]string) {
+	backendValuesMutex.Lock()
+	defer backendValuesMutex.Unlock()
+
+	backendValues = values
+}
+
+// loadConfigBackendSettings reads the CONFIG_BACKEND_* settings, returning nil when no backend is configured
+func loadConfigBackendSettings() (*configBackend, error) {
+	kind := strings.ToLower(getEnv("CONFIG_BACKEND"))
+	if kind == "" {
+		return nil, nil
+	}
+
+	backend := &configBackend{
+		Kind:   kind,
+		URL:    strings.TrimSuffix(getEnv("CONFIG_BACKEND_URL"), "/"),
+		Prefix: getEnv("CONFIG_BACKEND_PREFIX"),
+		Token:  getEnv("CONFIG_BACKEND_TOKEN"),
+	}
+
+	switch kind {
+	case "consul":
+		if backend.URL == "" {
+			backend.URL = "http://127.0.0.1:8500"
+		}
+	case "etcd":
+		if backend.URL == "" {
+			backend.URL = "http://127.0.0.1:2379"
+		}
+	case "ssm":
+		// The regional endpoint is used when no URL is set
+		if backend.Prefix != "" && !strings.HasPrefix(backend.Prefix, "/") {
+			return nil, fmt.Errorf("CONFIG_BACKEND_PREFIX must be a parameter path starting with / for ssm, got %q", backend.Prefix)
+		}
+	default:
+		return nil, fmt.Errorf("CONFIG_BACKEND must be \"consul\", \"etcd\" or \"ssm\", got %q", kind)
+	}
+
+	if backend.Prefix == "" {
+		return nil, fmt.Errorf("CONFIG_BACKEND_PREFIX is required when CONFIG_BACKEND is set")
+	}
+	if !strings.HasSuffix(backend.Prefix, "/") {
+		backend.Prefix += "/"
+	}
+
+	interval, err := getEnvDuration("CONFIG_BACKEND_WATCH_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	backend.WatchInterval = interval
+
+	return backend, nil
+}
+
+// fetch reads all keys under the prefix, keyed by the uppercased remainder of the key
+func (b *configBackend) fetch(ctx context.Context) (map[string]string, error) {
+	var raw map[string]string
+	var err error
+	switch b.Kind {
+	case "consul":
+		raw, err = b.fetchConsul(ctx)
+	case "ssm":
+		raw, err = b.fetchSSM(ctx)
+	default:
+		raw, err = b.fetchEtcd(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		name := strings.ToUpper(strings.TrimPrefix(key, b.Prefix))
+		if name == "" || strings.Contains(name, "/") {
+			continue
+		}
+		values[name] = strings.TrimSpace(value)
+	}
+	return values, nil
+}
+
+func (b *configBackend) fetchConsul(ctx context.Context) (map[string]string, error) {
+	endpoint := fmt.Sprintf("%s/v1/kv/%s?recurse=true", b.URL, strings.TrimPrefix(b.Prefix, "/"))
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if b.Token != "" {
+		req.Header.Set("X-Consul-Token", b.Token)
+	}
+
+	body, status, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+	// Consul answers 404 when the prefix has no keys yet
+	if status == http.StatusNotFound {
+		return map[string]string{}, nil
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("consul returned status %d: %s", status, string(body))
+	}
+
+	var entries []struct {
+		Key   string  `json:"Key"`
+		Value *string `json:"Value"`
+	}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if entry.Value == nil {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(*entry.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", entry.Key, err)
+		}
+		values[entry.Key] = string(decoded)
+	}
+	return values, nil
+}
+
+func (b *configBackend) fetchEtcd(ctx context.Context) (map[string]string, error) {
+	// The etcd v3 JSON gateway expects base64 keys; range_end is the prefix with its last byte incremented
+	rangeEnd := []byte(b.Prefix)
+	rangeEnd[len(rangeEnd)-1]++
+	payload, err := json.Marshal(map[string]string{
+		"key":       base64.StdEncoding.EncodeToString([]byte(b.Prefix)),
+		"range_end": base64.StdEncoding.EncodeToString(rangeEnd),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", b.URL+"/v3/kv/range", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.Token != "" {
+		req.Header.Set("Authorization", b.Token)
+	}
+
+	body, status, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("etcd returned status %d: %s", status, string(body))
+	}
+
+	var response struct {
+		Kvs []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(response.Kvs))
+	for _, kv := range response.Kvs {
+		key, err := base64.StdEncoding.DecodeString(kv.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key in etcd response: %w", err)
+		}
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", string(key), err)
+		}
+		values[string(key)] = string(value)
+	}
+	return values, nil
+}
+
+func (b *configBackend) do(req *http.Request) ([]byte, int, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Printf("Failed to close response body: %v", err)
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	return body, resp.StatusCode, err
+}
+
+// loadConfigBackend fetches the initial configuration values from the backend
+func loadConfigBackend(backend *configBackend) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	values, err := backend.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration from %s: %w", backend.Kind, err)
+	}
+
+	setBackendValues(values)
+	log.Printf("Loaded %d configuration keys from %s prefix %s", len(values), backend.Kind, backend.Prefix)
+	return nil
+}
+
+// watchConfigBackend polls the backend and calls reload whenever the stored values change.
+// If reload rejects the new values, the previous ones are restored.
+func watchConfigBackend(backend *configBackend, reload func() error) {
+	ticker := time.NewTicker(backend.WatchInterval)
+	defer ticker.Stop()
+
+	var rejected map[string]string
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		values, err := backend.fetch(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to refresh configuration from %s: %v", backend.Kind, err)
+			continue
+		}
+
+		backendValuesMutex.RLock()
+		previous := backendValues
+		backendValuesMutex.RUnlock()
+		if maps.Equal(previous, values) || (rejected != nil && maps.Equal(rejected, values)) {
+			continue
+		}
+
+		log.Printf("Configuration changed in %s, reloading", backend.Kind)
+		setBackendValues(values)
+		if err := reload(); err != nil {
+			log.Printf("Ignoring invalid configuration from %s, keeping the previous one: %v", backend.Kind, err)
+			setBackendValues(previous)
+			rejected = values
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// configKey documents a single configuration variable
+type configKey struct {
+	Name        string
+	Kind        string // "string", "bool", "int", "number", "duration", "url" or "cron"
+	Description string
+	Default     string
+	Required    bool
+	Enum        []string
+}
+
+// configKeys lists every supported configuration variable; keep it in sync with loadConfig
+var configKeys = []configKey{
+	{Name: "ACCOUNTID", Kind: "string", Description: "Your Cloudflare account ID, resolved automatically when the token has access to a single account"},
+	{Name: "RULEID", Kind: "string", Description: "Your Cloudflare Access Group rule ID, or a comma-separated list of them", Required: true},
+	{Name: "TARGET_PARALLELISM", Kind: "int", Description: "Number of Access Groups updated at the same time when RULEID lists several", Default: "4"},
+	{Name: "CRON", Kind: "cron", Description: "Cron schedule for checking and updating the IP, required unless running once, a subcommand or in serverless mode"},
+	{Name: "AUTH_TOKEN", Kind: "string", Description: "Your Cloudflare API Bearer token with appropriate permissions, required unless AUTH_TOKEN_FILE is set"},
+	{Name: "AUTH_TOKEN_FILE", Kind: "string", Description: "File holding the Cloudflare API token instead of AUTH_TOKEN, watched for a rotated token"},
+	{Name: "AUTH_TOKEN_SECONDARY", Kind: "string", Description: "Cloudflare API token used once Cloudflare rejects AUTH_TOKEN, e.g. when it was revoked or expired"},
+	{Name: "NOTIFICATION_URL", Kind: "url", Description: "Shoutrrr URL for notifications"},
+	{Name: "NOTIFICATION_IDENTIFIER", Kind: "string", Description: "A message added before the Shoutrrr message"},
+	{Name: "TEST_NOTIFICATION", Kind: "bool", Description: "Send a test notification on startup", Default: "false"},
+	{Name: "PROVIDER_RETRIES", Kind: "int", Description: "Quick retries against the same IP provider before moving to the next one", Default: "1"},
+	{Name: "PROVIDER_RETRY_BACKOFF", Kind: "duration", Description: "Delay before the first provider retry, doubled on each attempt", Default: "500ms"},
+	{Name: "PROVIDER_PASSES", Kind: "int", Description: "Passes over all IP providers within a run when every one failed, before giving up", Default: "1"},
+	{Name: "PROVIDER_PASS_BACKOFF", Kind: "duration", Description: "Delay before the second pass over the IP providers, doubled for each further pass", Default: "5s"},
+	{Name: "PROVIDER_BREAKER_THRESHOLD", Kind: "int", Description: "Consecutive failures after which a provider is skipped, 0 disables the circuit breaker", Default: "3"},
+	{Name: "PROVIDER_BREAKER_COOLDOWN", Kind: "duration", Description: "How long a failing provider is skipped before it is tried again", Default: "5m"},
+	{Name: "RUN_TIMEOUT", Kind: "duration", Description: "Overall deadline for a single check run", Default: "90s"},
+	{Name: "SHUTDOWN_TIMEOUT", Kind: "duration", Description: "How long a shutdown waits for a running check to finish", Default: "30s"},
+	{Name: "PROVIDER_TIMEOUT", Kind: "duration", Description: "Timeout for each IP provider request, 0 disables it", Default: "5s"},
+	{Name: "CLOUDFLARE_TIMEOUT", Kind: "duration", Description: "Timeout for each Cloudflare API request, 0 disables it", Default: "30s"},
+	{Name: "CLOUDFLARE_RATE_LIMIT", Kind: "number", Description: "Cloudflare API requests per second shared by all calls of the process, 0 disables limiting", Default: "3"},
+	{Name: "CLOUDFLARE_RATE_BURST", Kind: "int", Description: "Burst of Cloudflare API requests allowed above CLOUDFLARE_RATE_LIMIT", Default: "10"},
+	{Name: "NOTIFICATION_TIMEOUT", Kind: "duration", Description: "Timeout for each notification send, 0 disables it", Default: "30s"},
+	{Name: "HTTP_DEBUG", Kind: "bool", Description: "Log full HTTP request/response traces with credentials redacted", Default: "false"},
+	{Name: "TRACE_ZONE", Kind: "string", Description: "Hostname of a Cloudflare-proxied zone whose /cdn-cgi/trace is tried first"},
+	{Name: "PREFLIGHT_CHECK", Kind: "bool", Description: "Verify the Cloudflare API and token before each IP detection", Default: "false"},
+	{Name: "PREFLIGHT_RETRY_INTERVAL", Kind: "duration", Description: "Delay before retrying after a failed pre-flight check, 0 waits for the next cron run", Default: "1m"},
+	{Name: "DRY_RUN", Kind: "bool", Description: "Log Cloudflare changes without applying them", Default: "false"},
+	{Name: "CF_API_BASE_URL", Kind: "url", Description: "Cloudflare API base URL", Default: "https://api.cloudflare.com/client/v4"},
+	{Name: "CF_RECORD_FILE", Kind: "string", Description: "Append every Cloudflare request/response pair, redacted, to this JSON lines file"},
+	{Name: "CF_REPLAY_FILE", Kind: "string", Description: "Serve Cloudflare responses from a recorded file instead of calling the API"},
+	{Name: "PROFILE", Kind: "string", Description: "Named configuration profile to use"},
+	{Name: "ENV_FILE", Kind: "string", Description: "Comma-separated list of env files to load instead of ./.env"},
+	{Name: "CONFIG_BACKEND", Kind: "string", Description: "Load configuration from a key/value store", Enum: []string{"consul", "etcd", "ssm"}},
+	{Name: "CONFIG_BACKEND_URL", Kind: "url", Description: "Configuration backend address"},
+	{Name: "CONFIG_BACKEND_PREFIX", Kind: "string", Description: "Key prefix holding the configuration, a parameter path such as /cf-ip-updater/home/ for ssm"},
+	{Name: "CONFIG_BACKEND_TOKEN", Kind: "string", Description: "Consul ACL token, or etcd auth token"},
+	{Name: "CONFIG_BACKEND_WATCH_INTERVAL", Kind: "duration", Description: "How often to check the backend for changes, 0 disables watching", Default: "30s"},
+	{Name: "HTTP_RATE_LIMIT", Kind: "number", Description: "Requests per second allowed per client on the HTTP server, 0 disables limiting", Default: "10"},
+	{Name: "HTTP_RATE_BURST", Kind: "int", Description: "Burst of requests allowed per client on the HTTP server", Default: "20"},
+	{Name: "HTTP_MAX_BODY_BYTES", Kind: "int", Description: "Maximum request body size accepted by the HTTP server", Default: "65536"},
+	{Name: "HTTP_TRUST_PROXY_HEADERS", Kind: "bool", Description: "Identify clients by CF-Connecting-IP / X-Forwarded-For when behind a proxy or tunnel", Default: "false"},
+	{Name: "CORS_ALLOWED_ORIGINS", Kind: "string", Description: "Comma-separated origins allowed to call the HTTP endpoints from a browser, or *"},
+	{Name: "CORS_ALLOWED_METHODS", Kind: "string", Description: "Comma-separated methods allowed in CORS requests", Default: "GET, POST, OPTIONS"},
+	{Name: "CORS_ALLOWED_HEADERS", Kind: "string", Description: "Comma-separated request headers allowed in CORS requests", Default: "Authorization, Content-Type"},
+	{Name: "API_TOKEN", Kind: "string", Description: "Bearer token required by the control API endpoints; they are disabled when unset"},
+	{Name: "WEBHOOK_SECRET", Kind: "string", Description: "Secret of the Cloudflare notification webhook triggering drift checks; the webhook endpoint is disabled when unset"},
+	{Name: "STATE_FILE", Kind: "string", Description: "File keeping data between runs, such as the static IPs", Default: "state.json"},
+	{Name: "LOG_LEVEL", Kind: "string", Description: "Logging verbosity", Default: "info", Enum: []string{"debug", "info"}},
+	{Name: "LOG_FORMAT", Kind: "string", Description: "Log output format, pretty adds colors on a terminal", Default: "plain", Enum: []string{"plain", "pretty", "json"}},
+	{Name: "HEARTBEAT_INTERVAL", Kind: "duration", Description: "Interval of a heartbeat log line with the current IP, last change and next check, 0 disables it", Default: "0"},
+	{Name: "NOTIFY_GROUP_DIFF", Kind: "bool", Description: "Include a unified diff of the group JSON in update notifications", Default: "false"},
+	{Name: "NOTIFY_TIMINGS", Kind: "bool", Description: "Include the IP provider and the detection and update durations in update notifications", Default: "false"},
+	{Name: "UNIFI_URL", Kind: "url", Description: "Address of a UniFi console or Network controller to read the gateway's WAN address from"},
+	{Name: "UNIFI_API_KEY", Kind: "string", Description: "UniFi API key"},
+	{Name: "UNIFI_USERNAME", Kind: "string", Description: "UniFi local user, when not using an API key"},
+	{Name: "UNIFI_PASSWORD", Kind: "string", Description: "Password of the UniFi local user"},
+	{Name: "UNIFI_SITE", Kind: "string", Description: "UniFi site name", Default: "default"},
+	{Name: "UNIFI_INSECURE_SKIP_VERIFY", Kind: "bool", Description: "Accept the self-signed certificate of the UniFi console", Default: "false"},
+	{Name: "MULTI_WAN", Kind: "bool", Description: "Publish the IPs of all uplinks of a multi-WAN site", Default: "false"},
+	{Name: "WAN_INTERFACES", Kind: "string", Description: "Comma-separated local network interfaces of the uplinks, primary first, when running on the router"},
+	{Name: "TAILSCALE_DEVICE", Kind: "string", Description: "ID, hostname or MagicDNS name of a tailnet device whose public IP is tracked instead of our own"},
+	{Name: "TAILSCALE_API_KEY", Kind: "string", Description: "Tailscale API access token"},
+	{Name: "TAILSCALE_TAILNET", Kind: "string", Description: "Tailnet of the device", Default: "-"},
+	{Name: "TAILSCALE_API_URL", Kind: "url", Description: "Tailscale API base URL", Default: "https://api.tailscale.com"},
+	{Name: "CONFIRMATIONS", Kind: "int", Description: "Number of times a new IP must be observed before the Access Group is rewritten", Default: "1"},
+	{Name: "CONFIRM_BY", Kind: "string", Description: "How a new IP is confirmed: on consecutive checks, or by other IP providers in the same check", Default: "checks", Enum: []string{"checks", "providers"}},
+	{Name: "GROUP_SPEC_FILE", Kind: "string", Description: "JSON file declaring the full content of the Access Group, with the detected IP in place of {{dynamic_ip}}"},
+	{Name: "FLAP_THRESHOLD", Kind: "int", Description: "Changes of the detected IP within FLAP_WINDOW, going back to an earlier value, after which it is considered flapping and the group is held, 0 disables it", Default: "0"},
+	{Name: "FLAP_WINDOW", Kind: "duration", Description: "Time window of the flap detection", Default: "1h"},
+	{Name: "VERIFY_INTERVAL", Kind: "duration", Description: "Skip the Access Group lookup while the detected IP equals the one published and verified within this interval, 0 looks it up on every check", Default: "0"},
+	{Name: "IPV6_PREFIX_LENGTH", Kind: "int", Description: "Publish the delegated IPv6 prefix of this length, e.g. 56 or 64, instead of a single address; 0 disables it", Default: "0"},
+	{Name: "IPV6_PREFIX_INTERFACE", Kind: "string", Description: "Local network interface to read the delegated IPv6 prefix from, instead of IPv6 lookup services"},
+	{Name: "NOTIFICATION_MAX_LENGTH", Kind: "string", Description: "Maximum notification length in characters, or a comma-separated list of <scheme>=<limit>"},
+	{Name: "NOTIFICATION_PARAMS", Kind: "string", Description: "shoutrrr parameters of every notification as <key>=<value> pairs, e.g. title=IP Updater,priority=0"},
+	{Name: "NOTIFICATION_WARNING_PARAMS", Kind: "string", Description: "shoutrrr parameters of warning notifications, such as flapping or a token failover, overriding NOTIFICATION_PARAMS"},
+	{Name: "NOTIFICATION_ERROR_PARAMS", Kind: "string", Description: "shoutrrr parameters of failure notifications, overriding NOTIFICATION_PARAMS, e.g. priority=1"},
+	{Name: "LANGUAGE", Kind: "string", Description: "Language of the notifications: en, de, el or es; logs are always in English", Default: "en"},
+	{Name: "DISPLAY_TZ", Kind: "string", Description: "Time zone of timestamps in logs, notifications and the status API, e.g. Europe/Athens"},
+	{Name: "PORT", Kind: "int", Description: "Port the serverless HTTP handler listens on, as set by the platform", Default: "8080"},
+	{Name: "AWS_REGION", Kind: "string", Description: "AWS region of the Parameter Store with CONFIG_BACKEND=ssm"},
+	{Name: "AWS_DEFAULT_REGION", Kind: "string", Description: "AWS region used when AWS_REGION is not set"},
+	{Name: "AWS_ACCESS_KEY_ID", Kind: "string", Description: "AWS access key for CONFIG_BACKEND=ssm"},
+	{Name: "AWS_SECRET_ACCESS_KEY", Kind: "string", Description: "AWS secret key for CONFIG_BACKEND=ssm"},
+	{Name: "AWS_SESSION_TOKEN", Kind: "string", Description: "AWS session token of temporary credentials for CONFIG_BACKEND=ssm"},
+	{Name: "AWS_LAMBDA_RUNTIME_API", Kind: "string", Description: "Set by AWS Lambda, runs the updater as a Lambda function"},
+}
+
+// Patterns used in the schema for values that are plain strings in the environment
+const (
+	durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$|^0$`
+	intPattern      = `^[0-9]+$`
+	numberPattern   = `^[0-9]+(\.[0-9]+)?$`
+)
+
+// configJSONSchema builds a JSON Schema describing the configuration as a map of environment variables
+func configJSONSchema() map[string]interface{} {
+	properties := make(map[string]interface{}, len(configKeys))
+	var required []string
+
+	for _, key := range configKeys {
+		property := map[string]interface{}{
+			"type":        "string",
+			"description": key.Description,
+		}
+
+		switch key.Kind {
+		case "bool":
+			property["enum"] = []string{"true", "false"}
+		case "int":
+			property["pattern"] = intPattern
+		case "number":
+			property["pattern"] = numberPattern
+		case "duration":
+			property["pattern"] = durationPattern
+		case "url":
+			property["format"] = "uri"
+		case "cron":
+			property["examples"] = []string{"*/5 * * * *", "0 * * * *", "@hourly"}
+		}
+		if len(key.Enum) > 0 {
+			property["enum"] = key.Enum
+		}
+		if key.Default != "" {
+			property["default"] = key.Default
+		}
+
+		properties[key.Name] = property
+		if key.Required {
+			required = append(required, key.Name)
+		}
+	}
+
+	return map[string]interface{}{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         "https://github.com/htsachakis/CloudflareAccessGroupIPUpdater/config.schema.json",
+		"title":       "Cloudflare Access Group IP Updater configuration",
+		"description": "Environment variables (or .env file entries) accepted by the updater",
+		"type":        "object",
+		"properties":  properties,
+		"required":    required,
+		// The token is given directly or in a file
+		"anyOf": []interface{}{
+			map[string]interface{}{"required": []string{"AUTH_TOKEN"}},
+			map[string]interface{}{"required": []string{"AUTH_TOKEN_FILE"}},
+		},
+		// Profile-specific overrides, e.g. PROFILE_HOME_ACCOUNTID
+		"patternProperties": map[string]interface{}{
+			"^PROFILE_[A-Z0-9_]+$": map[string]interface{}{"type": "string"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+// runSchema prints the configuration JSON Schema to stdout
+func runSchema() error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(configJSONSchema())
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+// Ways a new IP is confirmed before the Access Group is rewritten
+const (
+	ConfirmByChecks    = "checks"
+	ConfirmByProviders = "providers"
+)
+
+// pendingIP is a new IP that was not yet observed often enough to be applied
+type pendingIP struct {
+	IP   string `json:"ip"`
+	Seen int    `json:"seen"`
+}
+
+// confirmNewIP reports whether a new IP was observed CONFIRMATIONS times, on consecutive checks or by
+// as many providers, so a single bogus provider response can't rewrite the group
+func confirmNewIP(ctx context.Context, config Configuration, ip string, source string) bool {
+	if config.Confirmations <= 1 {
+		return true
+	}
+
+	if config.ConfirmBy == ConfirmByProviders {
+		return confirmByProviders(ctx, config, ip, source)
+	}
+
+	// The count is kept in the state file, so it also works across --once runs
+	var seen int
+	err := updateState(config.StateFile, func(state *State) error {
+		pending := state.PendingIPs[config.RuleID]
+		if pending.IP != ip {
+			pending = pendingIP{IP: ip}
+		}
+		pending.Seen++
+		seen = pending.Seen
+
+		if seen >= config.Confirmations {
+			delete(state.PendingIPs, config.RuleID)
+			return nil
+		}
+		if state.PendingIPs == nil {
+			state.PendingIPs = map[string]pendingIP{}
+		}
+		state.PendingIPs[config.RuleID] = pending
+		return nil
+	})
+	if err != nil {
+		// Never updating would be worse than skipping the confirmation
+		log.Printf("Failed to remember the unconfirmed IP, applying it without confirmation: %v", err)
+		return true
+	}
+
+	if seen >= config.Confirmations {
+		log.Printf("New IP %s confirmed on %d consecutive checks", ip, seen)
+		return true
+	}
+	log.Printf("New IP %s seen on %d of %d consecutive checks, waiting for confirmation", ip, seen, config.Confirmations)
+	return false
+}
+
+// clearPendingIP forgets an unconfirmed IP once the group matches the detected IP again
+func clearPendingIP(config Configuration) {
+	if config.Confirmations <= 1 {
+		return
+	}
+
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		log.Printf("Failed to read the unconfirmed IP: %v", err)
+		return
+	}
+	pending, ok := state.PendingIPs[config.RuleID]
+	if !ok {
+		return
+	}
+
+	log.Printf("Unconfirmed IP %s was not seen again, discarding it", pending.IP)
+	err = updateState(config.StateFile, func(state *State) error {
+		delete(state.PendingIPs, config.RuleID)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to discard the unconfirmed IP: %v", err)
+	}
+}
+
+// confirmByProviders asks the other providers for the IP until CONFIRMATIONS of them, including
+// the one that detected it, agree
+func confirmByProviders(ctx context.Context, config Configuration, ip string, source string) bool {
+	if config.SimulateIP != "" {
+		log.Println("Simulated IP, skipping the confirmation by other providers")
+		return true
+	}
+
+	client := newHTTPClient(config, config.ProviderTimeout)
+	agreed := 1
+	var disagreed []string
+	for _, provider := range availableProviders(config, providersFor(config)) {
+		if agreed >= config.Confirmations {
+			break
+		}
+		if provider.name() == source || ctx.Err() != nil {
+			continue
+		}
+
+		start := time.Now()
+		other, err := fetchIPFromProvider(ctx, client, provider)
+		recordProviderResult(config, provider.URL, time.Since(start), err)
+		if err != nil {
+			continue
+		}
+		other = strings.TrimSpace(other)
+		if config.IPv6PrefixLength > 0 {
+			if other, err = ipv6Prefix(other, config.IPv6PrefixLength); err != nil {
+				continue
+			}
+		}
+
+		if other == ip {
+			agreed++
+		} else {
+			disagreed = append(disagreed, provider.name()+": "+other)
+		}
+	}
+
+	if agreed >= config.Confirmations {
+		log.Printf("New IP %s confirmed by %d providers", ip, agreed)
+		return true
+	}
+	log.Printf("New IP %s only confirmed by %d of %d providers, keeping the current one", ip, agreed, config.Confirmations)
+	if len(disagreed) > 0 {
+		log.Printf("Other providers answered: %s", strings.Join(disagreed, ", "))
+	}
+	return false
+}
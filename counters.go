@@ -0,0 +1,140 @@
+package main
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// countersMonths is the number of calendar months kept in the monthly counters
+const countersMonths = 12
+
+// counters are cumulative run statistics kept in the state file, so they survive restarts
+type counters struct {
+	Since      time.Time                  `json:"since"`
+	Checks     int64                      `json:"checks"`
+	Updates    int64                      `json:"updates"`
+	Failures   int64                      `json:"failures"`
+	LastUpdate time.Time                  `json:"last_update,omitzero"`
+	Monthly    map[string]monthlyCounters `json:"monthly,omitempty"`
+}
+
+// monthlyCounters are the statistics of one calendar month, keyed by YYYY-MM
+type monthlyCounters struct {
+	Checks   int64 `json:"checks"`
+	Updates  int64 `json:"updates"`
+	Failures int64 `json:"failures"`
+}
+
+// persistedCounters is the last known content of the counters, read by /ready without touching the state file
+var persistedCounters atomic.Pointer[counters]
+
+// loadCounters reads the counters from the state file at startup, when STATE_FILE is set
+func loadCounters(config Configuration) {
+	if !config.PersistCounters {
+		return
+	}
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		log.Printf("Failed to read the counters, starting from zero: %v", err)
+		return
+	}
+	if state.Counters != nil {
+		persistedCounters.Store(state.Counters)
+	}
+}
+
+// recordCounters adds a completed check run to the counters, dry runs and skipped runs don't count.
+// Without an explicit STATE_FILE the counters are only kept in memory.
+func recordCounters(config Configuration, result RunResult, at time.Time) {
+	if config.DryRun || result.Action == ActionSkipped {
+		return
+	}
+
+	if !config.PersistCounters {
+		var updated counters
+		if c := persistedCounters.Load(); c != nil {
+			updated = *c
+			updated.Monthly = maps.Clone(c.Monthly)
+		} else {
+			updated.Since = at
+		}
+		addRun(&updated, result, at)
+		persistedCounters.Store(&updated)
+		return
+	}
+
+	var updated counters
+	err := updateState(config.StateFile, func(state *State) error {
+		c := state.Counters
+		if c == nil {
+			c = &counters{Since: at}
+		}
+		addRun(c, result, at)
+		state.Counters = c
+		updated = *c
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to persist the counters: %v", err)
+		return
+	}
+	persistedCounters.Store(&updated)
+}
+
+// addRun counts a run in the totals and in its month, in UTC so the buckets don't depend on DISPLAY_TZ
+func addRun(c *counters, result RunResult, at time.Time) {
+	month := at.UTC().Format("2006-01")
+	if c.Monthly == nil {
+		c.Monthly = map[string]monthlyCounters{}
+	}
+	monthly := c.Monthly[month]
+
+	c.Checks++
+	monthly.Checks++
+	switch result.Action {
+	case ActionUpdated:
+		c.Updates++
+		monthly.Updates++
+		c.LastUpdate = at
+	case ActionError:
+		c.Failures++
+		monthly.Failures++
+	}
+	c.Monthly[month] = monthly
+
+	// Months sort chronologically by their keys
+	if months := slices.Sorted(maps.Keys(c.Monthly)); len(months) > countersMonths {
+		for _, old := range months[:len(months)-countersMonths] {
+			delete(c.Monthly, old)
+		}
+	}
+}
+
+// countersInfo describes the counters for /ready, with the current month broken out
+func countersInfo(config Configuration) map[string]interface{} {
+	c := persistedCounters.Load()
+	if c == nil {
+		return nil
+	}
+
+	thisMonth := c.Monthly[time.Now().UTC().Format("2006-01")]
+	info := map[string]interface{}{
+		"since":    displayTime(config, c.Since).Format(time.RFC3339),
+		"checks":   c.Checks,
+		"updates":  c.Updates,
+		"failures": c.Failures,
+		"this_month": map[string]int64{
+			"checks":   thisMonth.Checks,
+			"updates":  thisMonth.Updates,
+			"failures": thisMonth.Failures,
+		},
+		"monthly": c.Monthly,
+	}
+	if !c.LastUpdate.IsZero() {
+		info["last_update"] = displayTime(config, c.LastUpdate).Format(time.RFC3339)
+	}
+	return info
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// groupJSON returns the result object of a Cloudflare group response as indented JSON with sorted keys
+func groupJSON(body []byte) (string, error) {
+	var response struct {
+		Result interface{} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+
+	indented, err := json.MarshalIndent(response.Result, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(indented), nil
+}
+
+// groupDiff returns a unified diff between two Cloudflare group responses, or "" when they are identical
+func groupDiff(before, after []byte) (string, error) {
+	beforeJSON, err := groupJSON(before)
+	if err != nil {
+		return "", err
+	}
+	afterJSON, err := groupJSON(after)
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff("before", "after", beforeJSON, afterJSON), nil
+}
+
+// diffLine is a line of an edit script: ' ' unchanged, '-' removed or '+' added
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff compares two texts line by line and formats the differences as a unified diff
+func unifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+
+	a := strings.Split(from, "\n")
+	b := strings.Split(to, "\n")
+
+	// Longest common subsequence table, lcs[i][j] being the length for a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var script []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			script = append(script, diffLine{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			script = append(script, diffLine{'-', a[i]})
+			i++
+		default:
+			script = append(script, diffLine{'+', b[j]})
+			j++
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Group changes into hunks, merging those separated by less than twice the context
+	for start := 0; start < len(script); {
+		if script[start].op == ' ' {
+			start++
+			continue
+		}
+
+		hunkStart := max(start-diffContext, 0)
+		end := start
+		for end < len(script) {
+			if script[end].op != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(script) && script[next].op == ' ' {
+				next++
+			}
+			if next == len(script) || next-end > 2*diffContext {
+				break
+			}
+			end = next
+		}
+		hunkEnd := min(end+diffContext, len(script))
+
+		// Line numbers of the hunk in both texts
+		fromLine, toLine := 1, 1
+		for _, line := range script[:hunkStart] {
+			if line.op != '+' {
+				fromLine++
+			}
+			if line.op != '-' {
+				toLine++
+			}
+		}
+		fromCount, toCount := 0, 0
+		for _, line := range script[hunkStart:hunkEnd] {
+			if line.op != '+' {
+				fromCount++
+			}
+			if line.op != '-' {
+				toCount++
+			}
+		}
+
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", fromLine, fromCount, toLine, toCount)
+		for _, line := range script[hunkStart:hunkEnd] {
+			fmt.Fprintf(&buf, "%c%s\n", line.op, line.text)
+		}
+		start = hunkEnd
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// logGroupDiff logs the changes an update made to the Access Group at debug level and returns them.
+// Nothing is computed unless debug logging or NOTIFY_GROUP_DIFF is enabled.
+func logGroupDiff(config Configuration, before, after []byte) string {
+	if before == nil || after == nil || (config.LogLevel != "debug" && !config.NotifyGroupDiff) {
+		return ""
+	}
+
+	diff, err := groupDiff(before, after)
+	if err != nil {
+		log.Printf("Failed to diff the Cloudflare Access Group: %v", err)
+		return ""
+	}
+
+	if diff == "" {
+		debugf(config, "Cloudflare Access Group JSON unchanged by the update")
+	} else {
+		debugf(config, "Cloudflare Access Group changes:\n%s", diff)
+	}
+	return diff
+}
+
+// withGroupDiff appends the group diff to a notification message when NOTIFY_GROUP_DIFF is enabled
+func withGroupDiff(config Configuration, message, diff string) string {
+	if !config.NotifyGroupDiff || diff == "" {
+		return message
+	}
+	return message + "\n\n" + diff
+}
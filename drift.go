@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// managedIncludeCIDRs returns the include list the updater maintains: the IP first, followed by the static IPs
+func managedIncludeCIDRs(config Configuration, ip string) ([]string, error) {
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{ipToCIDR(ip)}, state.StaticIPs...), nil
+}
+
+// includeCIDRs lists the CIDRs of the include entries, "" for entries that aren't IP ranges
+func includeCIDRs(include []IncludeRule) []string {
+	cidrs := make([]string, len(include))
+	for i, rule := range include {
+		cidrs[i] = rule.IP.IP
+	}
+	return cidrs
+}
+
+// restoreGroupInclude rewrites the include list when it differs from the managed one although the IP is
+// current, e.g. after an entry was edited in the dashboard. It reports whether the group had drifted.
+func restoreGroupInclude(ctx context.Context, config Configuration, cfGroup *CloudflareResponse, ip string, result *RunResult) bool {
+	desired, err := managedIncludeCIDRs(config, ip)
+	if err != nil {
+		log.Printf("Failed to read the static IPs, not comparing the other include entries: %v", err)
+		return false
+	}
+	current := includeCIDRs(cfGroup.Result.Include)
+	if slices.Equal(current, desired) {
+		return false
+	}
+
+	log.Printf("Access Group include list %s differs from %s, restoring it", strings.Join(current, ", "), strings.Join(desired, ", "))
+	updateStart := time.Now()
+	updated, err := putCloudflareGroupIPs(ctx, config, desired)
+	result.UpdateTime = time.Since(updateStart)
+	if err != nil {
+		log.Printf("Error updating Cloudflare Access Group: %v", err)
+		result.fail(ErrorCategoryCloudflare, err)
+		if config.NotificationURL != "" {
+			if err := sendNotification(config, SeverityError, tr(config, "❌ Error updating Cloudflare Access Group: %v", err)); err != nil {
+				log.Printf("Failed to send notification: %v", err)
+			}
+		}
+		return true
+	}
+
+	log.Println("Successfully restored the Cloudflare Access Group include list")
+	result.Action = ActionUpdated
+	clearPendingIP(config)
+	rememberPublishedIP(config, ip)
+	diff := logGroupDiff(config, cfGroup.Raw, updated)
+	if config.NotificationURL != "" {
+		message := tr(config, "🛠️ Access Group include list restored: %s", strings.Join(desired, ", "))
+		if err := sendNotification(config, SeverityInfo, withGroupDiff(config, withTimings(config, message, *result), diff)); err != nil {
+			log.Printf("Failed to send notification: %v", err)
+		}
+	}
+	return true
+}
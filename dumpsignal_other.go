@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// notifyDumpSignal reports that state dumps are unavailable, as there is no SIGUSR2 on this platform
+func notifyDumpSignal(signals chan<- os.Signal) bool {
+	return false
+}
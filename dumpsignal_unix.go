@@ -0,0 +1,15 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyDumpSignal relays SIGUSR2 to the channel
+func notifyDumpSignal(signals chan<- os.Signal) bool {
+	signal.Notify(signals, syscall.SIGUSR2)
+	return true
+}
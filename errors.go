@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors callers can branch on with errors.Is instead of matching messages
+var (
+	// ErrGroupNotFound means the Access Group (ACCOUNTID/RULEID) doesn't exist or isn't visible to the token
+	ErrGroupNotFound = errors.New("access group not found")
+
+	// ErrRateLimited means an API answered 429 Too Many Requests
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrNoProviderAvailable means no IP provider returned an address
+	ErrNoProviderAvailable = errors.New("all IP providers failed")
+
+	// ErrValidation means an input, such as an IP address or the configuration, is invalid
+	ErrValidation = errors.New("validation failed")
+
+	// ErrUnauthorized means a request lacked the API_TOKEN it needs
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// StatusError is an unexpected HTTP status returned by the Cloudflare API, an IP provider or a gateway API
+type StatusError struct {
+	Message    string
+	StatusCode int
+	Body       string
+
+	// NotFound is the sentinel a 404 status matches, e.g. ErrGroupNotFound
+	NotFound error
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// Is matches ErrRateLimited on 429 and the NotFound sentinel on 404
+func (e *StatusError) Is(target error) bool {
+	switch {
+	case target == ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case e.NotFound != nil && target == e.NotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// validationError is an invalid input, matching ErrValidation while keeping its own message
+type validationError struct {
+	message string
+}
+
+func (e *validationError) Error() string {
+	return e.message
+}
+
+func (e *validationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// validationErrorf formats an error matching ErrValidation
+func validationErrorf(format string, args ...interface{}) error {
+	return &validationError{message: fmt.Sprintf(format, args...)}
+}
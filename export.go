@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats of the export subcommand
+const (
+	ExportTerraform = "terraform"
+	ExportSpec      = "spec"
+)
+
+// terraformLabelPattern matches the characters that aren't allowed in a Terraform resource name
+var terraformLabelPattern = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// managedDynamicIndex returns the position of the include entry the updater manages as the dynamic IP, -1 if none
+func managedDynamicIndex(config Configuration, group groupDetails) int {
+	if config.GroupSpecFile != "" {
+		template, err := loadGroupSpec(config.GroupSpecFile)
+		if err != nil {
+			log.Printf("Ignoring group spec: %v", err)
+			return -1
+		}
+		return dynamicEntryIndex(template)
+	}
+
+	// Without a spec the dynamic IP is the first include entry
+	if len(group.Result.Include) > 0 {
+		if _, ok := group.Result.Include[0]["ip"].(map[string]interface{}); ok {
+			return 0
+		}
+	}
+	return -1
+}
+
+// terraformLabel turns the group name into a valid Terraform resource name
+func terraformLabel(name string) string {
+	label := strings.Trim(terraformLabelPattern.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if label == "" {
+		return "access_group"
+	}
+	if label[0] >= '0' && label[0] <= '9' {
+		label = "group_" + label
+	}
+	return label
+}
+
+// hclValue renders a JSON value as an HCL expression
+func hclValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		// JSON escapes are valid in HCL, template sequences must be escaped on top
+		quoted, _ := json.Marshal(v)
+		return strings.NewReplacer("${", "$${", "%{", "%%{").Replace(string(quoted))
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = hclValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		return hclObject(v)
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+// hclObject renders an object on one line with sorted keys, e.g. { ip = { ip = "192.0.2.1/32" } }
+func hclObject(object map[string]interface{}) string {
+	if len(object) == 0 {
+		return "{}"
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := make([]string, len(keys))
+	for i, key := range keys {
+		fields[i] = key + " = " + hclValue(object[key])
+	}
+	return "{ " + strings.Join(fields, ", ") + " }"
+}
+
+// writeTerraform writes a cloudflare_zero_trust_access_group resource matching the live group
+func writeTerraform(w io.Writer, config Configuration, group groupDetails, dynamicIndex int) {
+	label := terraformLabel(group.Result.Name)
+	address := "cloudflare_zero_trust_access_group." + label
+
+	fmt.Fprintf(w, "# Cloudflare Access Group %s, exported by the Cloudflare Access Group IP Updater\n\n", group.Result.ID)
+	fmt.Fprintln(w, "# Adopts the existing group instead of creating a new one (Terraform 1.5+)")
+	fmt.Fprintln(w, "import {")
+	fmt.Fprintf(w, "  to = %s\n", address)
+	fmt.Fprintf(w, "  id = %s\n", hclValue(fmt.Sprintf("accounts/%s/%s", config.AccountID, group.Result.ID)))
+	fmt.Fprint(w, "}\n\n")
+
+	fmt.Fprintf(w, "resource \"cloudflare_zero_trust_access_group\" %q {\n", label)
+	fmt.Fprintf(w, "  account_id = %s\n", hclValue(config.AccountID))
+	fmt.Fprintf(w, "  name       = %s\n", hclValue(group.Result.Name))
+
+	for _, list := range []struct {
+		name  string
+		rules []map[string]interface{}
+	}{
+		{"include", group.Result.Include},
+		{"require", group.Result.Require},
+		{"exclude", group.Result.Exclude},
+	} {
+		fmt.Fprintln(w)
+		if len(list.rules) == 0 {
+			fmt.Fprintf(w, "  %s = []\n", list.name)
+			continue
+		}
+		fmt.Fprintf(w, "  %s = [\n", list.name)
+		for i, rule := range list.rules {
+			line := "    " + hclObject(rule) + ","
+			if list.name == "include" && i == dynamicIndex {
+				line += " # dynamic IP, managed by the updater"
+			}
+			fmt.Fprintln(w, line)
+		}
+		fmt.Fprintln(w, "  ]")
+	}
+
+	if dynamicIndex >= 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "  # The updater keeps rewriting the dynamic IP, Terraform must not revert it")
+		fmt.Fprintln(w, "  lifecycle {")
+		fmt.Fprintln(w, "    ignore_changes = [include]")
+		fmt.Fprintln(w, "  }")
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// writeSpec writes a GROUP_SPEC_FILE matching the live group, with the placeholder in the dynamic entry
+func writeSpec(w io.Writer, group groupDetails, dynamicIndex int) error {
+	spec := groupSpec{
+		Name:    group.Result.Name,
+		Include: group.Result.Include,
+		Require: group.Result.Require,
+		Exclude: group.Result.Exclude,
+	}
+	if dynamicIndex >= 0 {
+		spec.Include = append([]map[string]interface{}(nil), spec.Include...)
+		spec.Include[dynamicIndex] = map[string]interface{}{"ip": map[string]interface{}{"ip": dynamicIPPlaceholder}}
+	}
+	// Lists the group doesn't use are written empty rather than null
+	for _, list := range []*[]map[string]interface{}{&spec.Include, &spec.Require, &spec.Exclude} {
+		if *list == nil {
+			*list = []map[string]interface{}{}
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	// Keep {{dynamic_ip}} readable rather than escaping it
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(spec)
+}
+
+// runExport implements the export subcommand, writing the live Access Group in another format
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	profile := flags.String("profile", "", "Named configuration profile to use")
+	ruleID := flags.String("group", "", "Access Group rule ID when RULEID lists several (default: the first)")
+	format := flags.String("format", ExportTerraform, "Output format: terraform (a cloudflare_zero_trust_access_group resource) or spec (a GROUP_SPEC_FILE)")
+	_ = flags.Parse(args)
+
+	if *format != ExportTerraform && *format != ExportSpec {
+		log.Fatalf("Unknown export format %q, use %s or %s", *format, ExportTerraform, ExportSpec)
+	}
+
+	initConfigSources(*profile)
+	config, err := loadCommandConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *ruleID != "" {
+		config.RuleID = *ruleID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.RunTimeout)
+	defer cancel()
+
+	body, err := fetchCloudflareGroup(ctx, config)
+	if err != nil {
+		log.Fatalf("Error getting Cloudflare Access Group: %v", err)
+	}
+	var group groupDetails
+	if err := json.Unmarshal(body, &group); err != nil {
+		log.Fatal(err)
+	}
+
+	dynamicIndex := managedDynamicIndex(config, group)
+	switch *format {
+	case ExportTerraform:
+		writeTerraform(os.Stdout, config, group, dynamicIndex)
+	case ExportSpec:
+		if err := writeSpec(os.Stdout, group, dynamicIndex); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
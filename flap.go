@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// detectedIP is a change of the detected IP, kept to recognize an IP flapping between values
+type detectedIP struct {
+	IP string    `json:"ip"`
+	At time.Time `json:"at"`
+}
+
+// detectFlapping records the detected IP and reports whether it changed FLAP_THRESHOLD times within
+// FLAP_WINDOW going back to an earlier value, e.g. with dual-path routing or a broken provider.
+// An ISP renumbering several times in a row only ever moves to new values and is not held.
+func detectFlapping(config Configuration, ip string) (flapping bool) {
+	// Simulated IPs must not end up in the persisted history
+	if config.FlapThreshold == 0 || config.DryRun || config.SimulateIP != "" {
+		return false
+	}
+
+	var values []string
+	var started, ended bool
+	err := updateState(config.StateFile, func(state *State) error {
+		now := time.Now()
+		history := state.DetectedIPs
+		if len(history) == 0 || history[len(history)-1].IP != ip {
+			history = append(history, detectedIP{IP: ip, At: now})
+		}
+
+		// Forget changes that left the window; the value before the first remaining change is the baseline
+		cutoff := now.Add(-config.FlapWindow)
+		for len(history) > 1 && history[1].At.Before(cutoff) {
+			history = history[1:]
+		}
+		state.DetectedIPs = history
+
+		for _, change := range history {
+			if !slices.Contains(values, change.IP) {
+				values = append(values, change.IP)
+			}
+		}
+
+		// A value seen twice means the IP went back, rather than forward to a new one
+		flapping = len(history)-1 >= config.FlapThreshold && len(values) < len(history)
+		switch {
+		case flapping && state.FlappingSince.IsZero():
+			state.FlappingSince = now
+			started = true
+		case !flapping && !state.FlappingSince.IsZero():
+			state.FlappingSince = time.Time{}
+			ended = true
+		}
+		return nil
+	})
+	if err != nil {
+		// Damping is best effort, it must not stop the updates
+		log.Printf("Failed to record the detected IP for flap detection: %v", err)
+		return false
+	}
+
+	switch {
+	case started:
+		log.Printf("Warning: the detected IP is flapping between %s, holding the Access Group until it is stable for %s", strings.Join(values, ", "), config.FlapWindow)
+		if config.NotificationURL != "" {
+			message := tr(config, "⚠️ IP is flapping between %s, holding the current Access Group IP until it is stable for %s", strings.Join(values, ", "), config.FlapWindow)
+			if err := sendNotification(config, SeverityWarning, message); err != nil {
+				log.Printf("Failed to send notification: %v", err)
+			}
+		}
+	case ended:
+		log.Printf("The detected IP is stable again, resuming updates")
+	case flapping:
+		log.Printf("The detected IP is still flapping between %s, holding the Access Group", strings.Join(values, ", "))
+	}
+	return flapping
+}
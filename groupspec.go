@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// dynamicIPPlaceholder marks the entry of the group spec that receives the detected IP, as a CIDR
+const dynamicIPPlaceholder = "{{dynamic_ip}}"
+
+// groupSpec is the desired content of an Access Group, as declared in GROUP_SPEC_FILE
+type groupSpec struct {
+	Name    string                   `json:"name,omitempty"`
+	Include []map[string]interface{} `json:"include"`
+	Require []map[string]interface{} `json:"require"`
+	Exclude []map[string]interface{} `json:"exclude"`
+}
+
+// loadGroupSpec reads the group spec template, checking that it renders to a valid spec
+func loadGroupSpec(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read group spec: %w", err)
+	}
+
+	template := string(data)
+	spec, err := renderGroupSpec(template, "192.0.2.1")
+	if err != nil {
+		return "", err
+	}
+	if len(spec.Include) == 0 {
+		return "", validationErrorf("invalid group spec %s: include must have at least one entry", path)
+	}
+	return template, nil
+}
+
+// renderGroupSpec replaces the placeholder of the template with the CIDR of the IP and parses the result
+func renderGroupSpec(template string, ip string) (groupSpec, error) {
+	rendered := strings.ReplaceAll(template, dynamicIPPlaceholder, ipToCIDR(ip))
+
+	var spec groupSpec
+	decoder := json.NewDecoder(strings.NewReader(rendered))
+	// Catch typos such as "includes", which would otherwise silently empty a list
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return spec, validationErrorf("invalid group spec: %v", err)
+	}
+	return spec, nil
+}
+
+// withStaticIPs adds the static IPs of the static command to the include list, unless the spec has them
+func withStaticIPs(spec groupSpec, staticIPs []string) groupSpec {
+	for _, cidr := range staticIPs {
+		rule := map[string]interface{}{"ip": map[string]interface{}{"ip": cidr}}
+		if !slices.ContainsFunc(spec.Include, func(existing map[string]interface{}) bool {
+			return formatRule(existing) == formatRule(rule)
+		}) {
+			spec.Include = append(spec.Include, rule)
+		}
+	}
+	return spec
+}
+
+// dynamicEntryIndex returns the position of the include entry receiving the detected IP, -1 if none
+func dynamicEntryIndex(template string) int {
+	const marker = "198.51.100.254"
+	spec, err := renderGroupSpec(template, marker)
+	if err != nil {
+		return -1
+	}
+
+	for i, rule := range spec.Include {
+		if ip, ok := rule["ip"].(map[string]interface{}); ok && ip["ip"] == ipToCIDR(marker) {
+			return i
+		}
+	}
+	return -1
+}
+
+// dynamicEntryIP returns the IP the live group holds where the spec has its placeholder, "" if none
+func dynamicEntryIP(template string, live groupDetails) string {
+	i := dynamicEntryIndex(template)
+	if i < 0 || i >= len(live.Result.Include) {
+		return ""
+	}
+	if liveIP, ok := live.Result.Include[i]["ip"].(map[string]interface{}); ok {
+		cidr, _ := liveIP["ip"].(string)
+		return cidrToIP(cidr)
+	}
+	return ""
+}
+
+// specDrift lists the parts of the live group that differ from the spec
+func specDrift(spec groupSpec, live groupDetails) []string {
+	var drift []string
+	if spec.Name != "" && spec.Name != live.Result.Name {
+		drift = append(drift, "name")
+	}
+	for _, list := range []struct {
+		name        string
+		desired, is []map[string]interface{}
+	}{
+		{"include", spec.Include, live.Result.Include},
+		{"require", spec.Require, live.Result.Require},
+		{"exclude", spec.Exclude, live.Result.Exclude},
+	} {
+		if !sameRules(list.desired, list.is) {
+			drift = append(drift, list.name)
+		}
+	}
+	return drift
+}
+
+// sameRules compares two rule lists by their JSON, in which object keys are sorted
+func sameRules(a, b []map[string]interface{}) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aJSON, bJSON)
+}
+
+// putGroupSpecIP writes GROUP_SPEC_FILE to the Access Group with the given IP in place of the placeholder
+// and the given static IPs
+func putGroupSpecIP(ctx context.Context, config Configuration, ip string, staticIPs []string) error {
+	template, err := loadGroupSpec(config.GroupSpecFile)
+	if err != nil {
+		return err
+	}
+	spec, err := renderGroupSpec(template, ip)
+	if err != nil {
+		return err
+	}
+	spec = withStaticIPs(spec, staticIPs)
+	if spec.Name == "" {
+		group, err := getCloudflareGroup(ctx, config)
+		if err != nil {
+			return err
+		}
+		spec.Name = group.Result.Name
+	}
+
+	if config.DryRun {
+		specJSON, _ := json.Marshal(spec)
+		log.Printf("Dry run: would update Cloudflare Access Group %s with: %s", config.RuleID, specJSON)
+		return nil
+	}
+	_, err = putCloudflareGroup(ctx, config, spec)
+	return err
+}
+
+// syncGroupSpecStaticIPs rewrites the spec-managed Access Group with new static IPs, keeping its dynamic IP
+func syncGroupSpecStaticIPs(ctx context.Context, config Configuration, staticIPs []string) error {
+	template, err := loadGroupSpec(config.GroupSpecFile)
+	if err != nil {
+		return err
+	}
+	body, err := fetchCloudflareGroup(ctx, config)
+	if err != nil {
+		return err
+	}
+	var live groupDetails
+	if err := json.Unmarshal(body, &live); err != nil {
+		return err
+	}
+	ip := dynamicEntryIP(template, live)
+	if ip == "" {
+		return fmt.Errorf("the Access Group has no dynamic IP where the spec expects it, run a check first")
+	}
+	return putGroupSpecIP(ctx, config, ip, staticIPs)
+}
+
+// reconcileGroupSpec makes the live Access Group match GROUP_SPEC_FILE, with the detected IP in place of the placeholder
+func reconcileGroupSpec(ctx context.Context, config Configuration, currentIP string, result *RunResult) {
+	notifyError := func(message string, err error) {
+		if config.NotificationURL != "" {
+			if err := sendNotification(config, SeverityError, tr(config, message, err)); err != nil {
+				log.Printf("Failed to send notification: %v", err)
+			}
+		}
+	}
+
+	// Read the spec on every run, so edits apply without a restart
+	template, err := loadGroupSpec(config.GroupSpecFile)
+	if err != nil {
+		log.Printf("Error loading the group spec: %v", err)
+		result.fail(ErrorCategoryState, err)
+		notifyError("❌ Error loading the group spec: %v", err)
+		return
+	}
+
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		result.fail(ErrorCategoryState, err)
+		return
+	}
+
+	body, err := fetchCloudflareGroup(ctx, config)
+	if err != nil {
+		log.Printf("Error getting Cloudflare Access Group: %v", err)
+		if errors.Is(err, ErrGroupNotFound) {
+			log.Println("Check that ACCOUNTID and RULEID point to an existing Access Group the token can read")
+		}
+		result.fail(ErrorCategoryCloudflare, err)
+		notifyError("❌ Error getting Cloudflare Access Group: %v", err)
+		return
+	}
+	var live groupDetails
+	if err := json.Unmarshal(body, &live); err != nil {
+		result.fail(ErrorCategoryCloudflare, err)
+		return
+	}
+
+	// A new IP may still need confirmation, the rest of the spec is reconciled meanwhile
+	ip := currentIP
+	previousIP := dynamicEntryIP(template, live)
+	result.PreviousIP = previousIP
+	pending := false
+	if previousIP != "" && previousIP != currentIP && !confirmNewIP(ctx, config, currentIP, result.Provider) {
+		ip, pending = previousIP, true
+	}
+
+	spec, err := renderGroupSpec(template, ip)
+	if err != nil {
+		result.fail(ErrorCategoryState, err)
+		return
+	}
+	spec = withStaticIPs(spec, state.StaticIPs)
+	if spec.Name == "" {
+		spec.Name = live.Result.Name
+	}
+
+	drift := specDrift(spec, live)
+	if len(drift) == 0 {
+		if pending {
+			result.Action = ActionPending
+		} else {
+			log.Println("Access Group matches the spec, no action needed")
+			result.Action = ActionNoChange
+			clearPendingIP(config)
+		}
+		return
+	}
+
+	log.Printf("Access Group differs from the spec in: %s", strings.Join(drift, ", "))
+	var updated []byte
+	updateStart := time.Now()
+	if config.DryRun {
+		specJSON, _ := json.Marshal(spec)
+		log.Printf("Dry run: would update Cloudflare Access Group %s with: %s", config.RuleID, specJSON)
+	} else {
+		updated, err = putCloudflareGroup(ctx, config, spec)
+	}
+	result.UpdateTime = time.Since(updateStart)
+	if err != nil {
+		log.Printf("Error updating Cloudflare Access Group: %v", err)
+		result.fail(ErrorCategoryCloudflare, err)
+		notifyError("❌ Error updating Cloudflare Access Group: %v", err)
+		return
+	}
+
+	log.Println("Successfully reconciled the Cloudflare Access Group with the spec")
+	result.Action = ActionUpdated
+	diff := logGroupDiff(config, body, updated)
+	if config.NotificationURL != "" {
+		var message string
+		if previousIP != "" && previousIP != ip {
+			message = tr(config, "🔄 IP Address Updated: %s ➡️ %s", previousIP, ip)
+		} else {
+			message = tr(config, "🛠️ Access Group reconciled with the spec: %s", strings.Join(drift, ", "))
+		}
+		if err := sendNotification(config, SeverityInfo, withGroupDiff(config, withTimings(config, message, *result), diff)); err != nil {
+			log.Printf("Failed to send notification: %v", err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// logHeartbeat logs a line every HEARTBEAT_INTERVAL, so log-based monitoring can tell a quiet updater
+// from a wedged one
+func logHeartbeat(c *cron.Cron, interval time.Duration) {
+	for range time.Tick(interval) {
+		log.Println(heartbeatLine(c))
+	}
+}
+
+// heartbeatLine describes the current IP, the last change and check, and the next scheduled check
+func heartbeatLine(c *cron.Cron) string {
+	config := *activeConfig.Load()
+	ip := "unknown"
+	if current := sessionLastIP.Load(); current != nil {
+		ip = *current
+	}
+
+	var lastChange time.Time
+	if updatedAt := lastUpdateAt.Load(); updatedAt != nil {
+		lastChange = *updatedAt
+	} else if persisted := persistedCounters.Load(); persisted != nil {
+		lastChange = persisted.LastUpdate
+	}
+
+	var lastCheck, nextRun time.Time
+	if run := lastRunResult.Load(); run != nil {
+		lastCheck = run.At
+	}
+	for _, entry := range c.Entries() {
+		if nextRun.IsZero() || entry.Next.Before(nextRun) {
+			nextRun = entry.Next
+		}
+	}
+
+	return fmt.Sprintf("Heartbeat: current IP %s, last change %s, last check %s, next check %s",
+		ip, formatTime(config, lastChange), formatTime(config, lastCheck), formatTime(config, nextRun))
+}
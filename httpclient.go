@@ -0,0 +1,114 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Headers that carry credentials and must never be logged
+var sensitiveHeaderPattern = regexp.MustCompile(`(?im)^(Authorization|Proxy-Authorization|Cookie|Set-Cookie|X-Auth-Key|X-Auth-Email|X-Auth-User-Service-Key|X-API-Key|X-CSRF-Token):.*$`)
+
+// JSON fields that commonly hold credentials in request or response bodies
+var sensitiveFieldPattern = regexp.MustCompile(`(?i)("(?:token|access_token|api_key|apikey|password|secret|value)"\s*:\s*)"[^"]*"`)
+
+// debugTransport logs redacted request/response traces for every HTTP call
+type debugTransport struct {
+	next    http.RoundTripper
+	secrets []string
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if dump, err := httputil.DumpRequestOut(req, true); err == nil {
+		log.Printf("HTTP request:\n%s", t.redact(string(dump)))
+	} else {
+		log.Printf("HTTP request: %s %s (failed to dump: %v)", req.Method, req.URL, err)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		log.Printf("HTTP response: %s %s failed after %s: %v", req.Method, req.URL, time.Since(start), err)
+		return nil, err
+	}
+
+	if dump, err := httputil.DumpResponse(resp, true); err == nil {
+		log.Printf("HTTP response for %s %s (%s):\n%s", req.Method, req.URL, time.Since(start), t.redact(string(dump)))
+	} else {
+		log.Printf("HTTP response for %s %s: status %d (failed to dump: %v)", req.Method, req.URL, resp.StatusCode, err)
+	}
+
+	return resp, nil
+}
+
+// redact removes credentials from a dumped request or response
+func (t *debugTransport) redact(dump string) string {
+	return redactSecrets(dump, t.secrets)
+}
+
+// redactSecrets masks credential headers, credential JSON fields and known secret values
+func redactSecrets(text string, secrets []string) string {
+	text = sensitiveHeaderPattern.ReplaceAllString(text, "$1: [REDACTED]")
+	text = sensitiveFieldPattern.ReplaceAllString(text, `$1"[REDACTED]"`)
+	for _, secret := range secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, "[REDACTED]")
+		}
+	}
+	return text
+}
+
+// newHTTPClient creates an HTTP client with the given timeout, tracing traffic when HTTP_DEBUG is enabled
+func newHTTPClient(config Configuration, timeout time.Duration) *http.Client {
+	return wrapHTTPClient(config, timeout, http.DefaultTransport)
+}
+
+// newCloudflareClient creates the HTTP client used for Cloudflare API calls,
+// recording or replaying interactions when configured
+func newCloudflareClient(config Configuration) *http.Client {
+	var transport http.RoundTripper = http.DefaultTransport
+	if limiter := cloudflareRateLimiter(config); limiter != nil {
+		transport = &rateLimitTransport{next: transport, limiter: limiter, config: config}
+	}
+	if config.ReplayFile != "" {
+		transport = cassetteReplayer(config.ReplayFile)
+	} else if config.RecordFile != "" {
+		transport = &recordTransport{
+			next:    transport,
+			path:    config.RecordFile,
+			secrets: configSecrets(config),
+		}
+	}
+	if config.AuthTokenSecondary != "" {
+		transport = &tokenFailoverTransport{
+			next:      transport,
+			primary:   config.AuthToken,
+			secondary: config.AuthTokenSecondary,
+		}
+	}
+	transport = &componentTransport{next: transport}
+
+	return wrapHTTPClient(config, config.CloudflareTimeout, transport)
+}
+
+func wrapHTTPClient(config Configuration, timeout time.Duration, transport http.RoundTripper) *http.Client {
+	if config.HTTPDebug {
+		transport = &debugTransport{
+			next:    transport,
+			secrets: configSecrets(config),
+		}
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// configSecrets lists the configured credentials, masked wherever requests are logged or recorded
+func configSecrets(config Configuration) []string {
+	return []string{config.AuthToken, config.AuthTokenSecondary, config.APIToken, config.WebhookSecret, config.NotificationURL, config.UniFiAPIKey, config.UniFiPassword, config.TailscaleAPIKey}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// messageCatalogs translates notification messages, keyed by language and then by the English format
+// string. English is the default and needs no catalog; missing translations fall back to English.
+// Log messages are deliberately not translated, so they stay searchable and can be shared in issues.
+var messageCatalogs = map[string]map[string]string{
+	"de": {
+		"❌ Error getting current IP: %v":                       "❌ Fehler beim Ermitteln der aktuellen IP: %v",
+		"❌ Error getting Cloudflare Access Group: %v":          "❌ Fehler beim Abrufen der Cloudflare-Access-Gruppe: %v",
+		"❌ Error updating Cloudflare Access Group: %v":         "❌ Fehler beim Aktualisieren der Cloudflare-Access-Gruppe: %v",
+		"✅ Initial IP set in Cloudflare Access Group: %s":      "✅ Erste IP in der Cloudflare-Access-Gruppe gesetzt: %s",
+		"❌ Failed to update IP from %s to %s: %v":              "❌ IP konnte nicht von %s auf %s aktualisiert werden: %v",
+		"🔄 IP Address Updated: %s ➡️ %s":                       "🔄 IP-Adresse aktualisiert: %s ➡️ %s",
+		"🚀 Cloudflare IP Updater started - Test notification":  "🚀 Cloudflare IP Updater gestartet – Testbenachrichtigung",
+		"⏹️ Cloudflare IP Updater stopped":                     "⏹️ Cloudflare IP Updater gestoppt",
+		"❌ Error getting WAN IPs: %v":                          "❌ Fehler beim Ermitteln der WAN-IPs: %v",
+		"❌ Failed to update WAN IPs to %s: %v":                 "❌ WAN-IPs konnten nicht auf %s aktualisiert werden: %v",
+		"🔄 WAN IPs Updated: %s ➡️ %s":                          "🔄 WAN-IPs aktualisiert: %s ➡️ %s",
+		"✅ Initial WAN IPs set in Cloudflare Access Group: %s": "✅ Erste WAN-IPs in der Cloudflare-Access-Gruppe gesetzt: %s",
+		"✋ IP manually set in Cloudflare Access Group: %s":     "✋ IP manuell in der Cloudflare-Access-Gruppe gesetzt: %s",
+		"📌 Static IP %s added to Cloudflare Access Group":      "📌 Statische IP %s zur Cloudflare-Access-Gruppe hinzugefügt",
+		"📌 Static IP %s removed from Cloudflare Access Group":  "📌 Statische IP %s aus der Cloudflare-Access-Gruppe entfernt",
+		"[DRY RUN] ": "[TESTLAUF] ",
+		"Detected via %s in %s, Cloudflare update took %s": "Erkannt über %s in %s, Cloudflare-Aktualisierung dauerte %s",
+		"Uptime: %s":              "Laufzeit: %s",
+		"Checks: %d, updates: %d": "Prüfungen: %d, Aktualisierungen: %d",
+		"Last IP: %s":             "Letzte IP: %s",
+		"Last error (%s): %s":     "Letzter Fehler (%s): %s",
+		"⚠️ IP is flapping between %s, holding the current Access Group IP until it is stable for %s": "⚠️ Die IP wechselt ständig zwischen %s, die aktuelle IP der Access-Gruppe wird beibehalten, bis sie %s lang stabil ist",
+		"❌ Error loading the group spec: %v":                                                               "❌ Fehler beim Laden der Gruppenspezifikation: %v",
+		"🛠️ Access Group reconciled with the spec: %s":                                                     "🛠️ Access-Gruppe an die Spezifikation angeglichen: %s",
+		"🛠️ Access Group include list restored: %s":                                                        "🛠️ Include-Liste der Access-Gruppe wiederhergestellt: %s",
+		"🔑 Cloudflare API token rotated":                                                                   "🔑 Cloudflare-API-Token rotiert",
+		"❌ Error rotating the Cloudflare API token: %v":                                                    "❌ Fehler beim Rotieren des Cloudflare-API-Tokens: %v",
+		"⚠️ The primary Cloudflare API token was rejected (status %d), failed over to the secondary token": "⚠️ Das primäre Cloudflare-API-Token wurde abgelehnt (Status %d), Wechsel zum sekundären Token",
+		"%d of %d Access Groups changed or failed:":                                                        "%d von %d Access-Gruppen geändert oder fehlgeschlagen:",
+		"🧪 Test notification from the Cloudflare IP Updater":                                               "🧪 Testbenachrichtigung vom Cloudflare IP Updater",
+		"⚠️ Test warning from the Cloudflare IP Updater":                                                   "⚠️ Testwarnung vom Cloudflare IP Updater",
+		"❌ Test error from the Cloudflare IP Updater":                                                      "❌ Testfehler vom Cloudflare IP Updater",
+	},
+	"el": {
+		"❌ Error getting current IP: %v":                       "❌ Σφάλμα κατά τη λήψη της τρέχουσας IP: %v",
+		"❌ Error getting Cloudflare Access Group: %v":          "❌ Σφάλμα κατά τη λήψη της ομάδας Cloudflare Access: %v",
+		"❌ Error updating Cloudflare Access Group: %v":         "❌ Σφάλμα κατά την ενημέρωση της ομάδας Cloudflare Access: %v",
+		"✅ Initial IP set in Cloudflare Access Group: %s":      "✅ Ορίστηκε η αρχική IP στην ομάδα Cloudflare Access: %s",
+		"❌ Failed to update IP from %s to %s: %v":              "❌ Αποτυχία ενημέρωσης της IP από %s σε %s: %v",
+		"🔄 IP Address Updated: %s ➡️ %s":                       "🔄 Η διεύθυνση IP ενημερώθηκε: %s ➡️ %s",
+		"🚀 Cloudflare IP Updater started - Test notification":  "🚀 Το Cloudflare IP Updater ξεκίνησε - Δοκιμαστική ειδοποίηση",
+		"⏹️ Cloudflare IP Updater stopped":                     "⏹️ Το Cloudflare IP Updater σταμάτησε",
+		"❌ Error getting WAN IPs: %v":                          "❌ Σφάλμα κατά τη λήψη των IP WAN: %v",
+		"❌ Failed to update WAN IPs to %s: %v":                 "❌ Αποτυχία ενημέρωσης των IP WAN σε %s: %v",
+		"🔄 WAN IPs Updated: %s ➡️ %s":                          "🔄 Οι IP WAN ενημερώθηκαν: %s ➡️ %s",
+		"✅ Initial WAN IPs set in Cloudflare Access Group: %s": "✅ Ορίστηκαν οι αρχικές IP WAN στην ομάδα Cloudflare Access: %s",
+		"✋ IP manually set in Cloudflare Access Group: %s":     "✋ Η IP ορίστηκε χειροκίνητα στην ομάδα Cloudflare Access: %s",
+		"📌 Static IP %s added to Cloudflare Access Group":      "📌 Η στατική IP %s προστέθηκε στην ομάδα Cloudflare Access",
+		"📌 Static IP %s removed from Cloudflare Access Group":  "📌 Η στατική IP %s αφαιρέθηκε από την ομάδα Cloudflare Access",
+		"[DRY RUN] ": "[ΔΟΚΙΜΑΣΤΙΚΗ ΕΚΤΕΛΕΣΗ] ",
+		"Detected via %s in %s, Cloudflare update took %s": "Εντοπίστηκε μέσω %s σε %s, η ενημέρωση του Cloudflare διήρκεσε %s",
+		"Uptime: %s":              "Χρόνος λειτουργίας: %s",
+		"Checks: %d, updates: %d": "Έλεγχοι: %d, ενημερώσεις: %d",
+		"Last IP: %s":             "Τελευταία IP: %s",
+		"Last error (%s): %s":     "Τελευταίο σφάλμα (%s): %s",
+		"⚠️ IP is flapping between %s, holding the current Access Group IP until it is stable for %s": "⚠️ Η IP εναλλάσσεται μεταξύ %s, η τρέχουσα IP της ομάδας Access διατηρείται μέχρι να σταθεροποιηθεί για %s",
+		"❌ Error loading the group spec: %v":                                                               "❌ Σφάλμα κατά τη φόρτωση της προδιαγραφής της ομάδας: %v",
+		"🛠️ Access Group reconciled with the spec: %s":                                                     "🛠️ Η ομάδα Access ευθυγραμμίστηκε με την προδιαγραφή: %s",
+		"🛠️ Access Group include list restored: %s":                                                        "🛠️ Η λίστα include της ομάδας Access αποκαταστάθηκε: %s",
+		"🔑 Cloudflare API token rotated":                                                                   "🔑 Το Cloudflare API token εναλλάχθηκε",
+		"❌ Error rotating the Cloudflare API token: %v":                                                    "❌ Σφάλμα κατά την εναλλαγή του Cloudflare API token: %v",
+		"⚠️ The primary Cloudflare API token was rejected (status %d), failed over to the secondary token": "⚠️ Το κύριο Cloudflare API token απορρίφθηκε (κατάσταση %d), έγινε μετάβαση στο δευτερεύον token",
+		"%d of %d Access Groups changed or failed:":                                                        "%d από %d ομάδες Access άλλαξαν ή απέτυχαν:",
+		"🧪 Test notification from the Cloudflare IP Updater":                                               "🧪 Δοκιμαστική ειδοποίηση από το Cloudflare IP Updater",
+		"⚠️ Test warning from the Cloudflare IP Updater":                                                   "⚠️ Δοκιμαστική προειδοποίηση από το Cloudflare IP Updater",
+		"❌ Test error from the Cloudflare IP Updater":                                                      "❌ Δοκιμαστικό σφάλμα από το Cloudflare IP Updater",
+	},
+	"es": {
+		"❌ Error getting current IP: %v":                       "❌ Error al obtener la IP actual: %v",
+		"❌ Error getting Cloudflare Access Group: %v":          "❌ Error al obtener el grupo de Cloudflare Access: %v",
+		"❌ Error updating Cloudflare Access Group: %v":         "❌ Error al actualizar el grupo de Cloudflare Access: %v",
+		"✅ Initial IP set in Cloudflare Access Group: %s":      "✅ IP inicial establecida en el grupo de Cloudflare Access: %s",
+		"❌ Failed to update IP from %s to %s: %v":              "❌ No se pudo actualizar la IP de %s a %s: %v",
+		"🔄 IP Address Updated: %s ➡️ %s":                       "🔄 Dirección IP actualizada: %s ➡️ %s",
+		"🚀 Cloudflare IP Updater started - Test notification":  "🚀 Cloudflare IP Updater iniciado - Notificación de prueba",
+		"⏹️ Cloudflare IP Updater stopped":                     "⏹️ Cloudflare IP Updater detenido",
+		"❌ Error getting WAN IPs: %v":                          "❌ Error al obtener las IP WAN: %v",
+		"❌ Failed to update WAN IPs to %s: %v":                 "❌ No se pudieron actualizar las IP WAN a %s: %v",
+		"🔄 WAN IPs Updated: %s ➡️ %s":                          "🔄 IP WAN actualizadas: %s ➡️ %s",
+		"✅ Initial WAN IPs set in Cloudflare Access Group: %s": "✅ IP WAN iniciales establecidas en el grupo de Cloudflare Access: %s",
+		"✋ IP manually set in Cloudflare Access Group: %s":     "✋ IP establecida manualmente en el grupo de Cloudflare Access: %s",
+		"📌 Static IP %s added to Cloudflare Access Group":      "📌 IP estática %s añadida al grupo de Cloudflare Access",
+		"📌 Static IP %s removed from Cloudflare Access Group":  "📌 IP estática %s eliminada del grupo de Cloudflare Access",
+		"[DRY RUN] ": "[SIMULACIÓN] ",
+		"Detected via %s in %s, Cloudflare update took %s": "Detectada mediante %s en %s, la actualización de Cloudflare tardó %s",
+		"Uptime: %s":              "Tiempo activo: %s",
+		"Checks: %d, updates: %d": "Comprobaciones: %d, actualizaciones: %d",
+		"Last IP: %s":             "Última IP: %s",
+		"Last error (%s): %s":     "Último error (%s): %s",
+		"⚠️ IP is flapping between %s, holding the current Access Group IP until it is stable for %s": "⚠️ La IP alterna entre %s, se mantiene la IP actual del grupo de Access hasta que sea estable durante %s",
+		"❌ Error loading the group spec: %v":                                                               "❌ Error al cargar la especificación del grupo: %v",
+		"🛠️ Access Group reconciled with the spec: %s":                                                     "🛠️ Grupo de Access conciliado con la especificación: %s",
+		"🛠️ Access Group include list restored: %s":                                                        "🛠️ Lista include del grupo de Access restaurada: %s",
+		"🔑 Cloudflare API token rotated":                                                                   "🔑 Token de la API de Cloudflare rotado",
+		"❌ Error rotating the Cloudflare API token: %v":                                                    "❌ Error al rotar el token de la API de Cloudflare: %v",
+		"⚠️ The primary Cloudflare API token was rejected (status %d), failed over to the secondary token": "⚠️ El token principal de la API de Cloudflare fue rechazado (estado %d), se cambió al token secundario",
+		"%d of %d Access Groups changed or failed:":                                                        "%d de %d grupos de Access cambiaron o fallaron:",
+		"🧪 Test notification from the Cloudflare IP Updater":                                               "🧪 Notificación de prueba del Cloudflare IP Updater",
+		"⚠️ Test warning from the Cloudflare IP Updater":                                                   "⚠️ Advertencia de prueba del Cloudflare IP Updater",
+		"❌ Test error from the Cloudflare IP Updater":                                                      "❌ Error de prueba del Cloudflare IP Updater",
+	},
+}
+
+// tr formats a notification message in the configured language
+func tr(config Configuration, format string, args ...interface{}) string {
+	if translated, ok := messageCatalogs[config.Language][format]; ok {
+		format = translated
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// normalizeLanguage reduces a LANGUAGE value, which may also be a POSIX locale list such as
+// "de_DE.UTF-8:en", to a catalog language, reporting whether it is supported
+func normalizeLanguage(value string) (string, bool) {
+	language := strings.ToLower(strings.SplitN(value, ":", 2)[0])
+	if i := strings.IndexAny(language, "_-."); i >= 0 {
+		language = language[:i]
+	}
+	if language == "" || language == "en" {
+		return "en", true
+	}
+	_, ok := messageCatalogs[language]
+	return language, ok
+}
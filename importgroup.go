@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+// includeIPs returns the CIDRs of the include entries, "" for rules that aren't IP ranges
+func includeIPs(group groupDetails) []string {
+	cidrs := make([]string, len(group.Result.Include))
+	for i, rule := range group.Result.Include {
+		if ip, ok := rule["ip"].(map[string]interface{}); ok && len(rule) == 1 {
+			cidrs[i], _ = ip["ip"].(string)
+		}
+	}
+	return cidrs
+}
+
+// onlyIPRanges reports whether the group consists of include IP ranges only, which static IPs can represent
+func onlyIPRanges(group groupDetails) bool {
+	return len(group.Result.Require) == 0 && len(group.Result.Exclude) == 0 && !slices.Contains(includeIPs(group), "")
+}
+
+// findDynamicEntry returns the position of the include entry to manage as the dynamic IP: the given IP or CIDR,
+// else the entry holding the detected IP, else the first IP range. It returns -1 when the group has no IP range.
+func findDynamicEntry(ctx context.Context, config Configuration, group groupDetails, dynamic string) (int, error) {
+	cidrs := includeIPs(group)
+
+	if dynamic != "" {
+		cidr, err := normalizeCIDR(dynamic)
+		if err != nil {
+			return -1, err
+		}
+		for i, entry := range cidrs {
+			if normalized, err := normalizeCIDR(entry); err == nil && normalized == cidr {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("%s is not an include entry of the Access Group", cidr)
+	}
+
+	ip, _, err := getCurrentIP(ctx, config)
+	if err == nil && config.IPv6PrefixLength > 0 {
+		ip, err = ipv6Prefix(strings.TrimSpace(ip), config.IPv6PrefixLength)
+	}
+	if err != nil {
+		log.Printf("Failed to detect the current IP: %v", err)
+	} else {
+		current := ipToCIDR(strings.TrimSpace(ip))
+		for i, entry := range cidrs {
+			if normalized, err := normalizeCIDR(entry); err == nil && normalized == current {
+				log.Printf("The detected IP %s is entry %d of the include list", current, i+1)
+				return i, nil
+			}
+		}
+		log.Printf("The detected IP %s is not in the Access Group", current)
+	}
+
+	for i, entry := range cidrs {
+		if entry != "" {
+			log.Printf("Adopting the first IP range %s as the dynamic IP, it is replaced on the next run (use --dynamic to pick another)", entry)
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// runImport implements the import subcommand, adopting the contents of an existing hand-built Access Group
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	profile := flags.String("profile", "", "Named configuration profile to use")
+	ruleID := flags.String("group", "", "Access Group rule ID when RULEID lists several (default: the first)")
+	dynamic := flags.String("dynamic", "", "IP or CIDR of the include entry to manage as the dynamic IP (default: the entry holding the detected IP)")
+	specFile := flags.String("spec-file", "", "Write the group to this group spec file instead of recording static IPs")
+	dryRun := flags.Bool("dry-run", false, "Show what would be recorded without writing anything")
+	_ = flags.Parse(args)
+
+	initConfigSources(*profile)
+	config, err := loadCommandConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *ruleID != "" {
+		config.RuleID = *ruleID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.RunTimeout)
+	defer cancel()
+
+	body, err := fetchCloudflareGroup(ctx, config)
+	if err != nil {
+		log.Fatalf("Error getting Cloudflare Access Group: %v", err)
+	}
+	var group groupDetails
+	if err := json.Unmarshal(body, &group); err != nil {
+		log.Fatal(err)
+	}
+
+	dynamicIndex, err := findDynamicEntry(ctx, config, group, *dynamic)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cidrs := includeIPs(group)
+	if dynamicIndex >= 0 {
+		fmt.Printf("Dynamic IP: %s (include entry %d)\n", cidrs[dynamicIndex], dynamicIndex+1)
+	} else {
+		fmt.Println("Dynamic IP: none yet, it is added as a new include entry")
+	}
+
+	if *specFile == "" && !onlyIPRanges(group) {
+		log.Fatalf("The Access Group has rules other than include IP ranges, which static IPs can't hold. Use --spec-file <file> to adopt it as a group spec.")
+	}
+
+	// Everything else becomes declared content of a group spec...
+	if *specFile != "" {
+		if dynamicIndex < 0 {
+			// Give the detected IP an entry of its own
+			group.Result.Include = append([]map[string]interface{}{{"ip": map[string]interface{}{"ip": dynamicIPPlaceholder}}}, group.Result.Include...)
+			dynamicIndex = 0
+		}
+
+		var spec bytes.Buffer
+		if err := writeSpec(&spec, group, dynamicIndex); err != nil {
+			log.Fatal(err)
+		}
+		if *dryRun {
+			fmt.Printf("Dry run: would write %s:\n%s", *specFile, spec.String())
+			return
+		}
+
+		file, err := os.OpenFile(*specFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			log.Fatalf("%s already exists, remove it or choose another --spec-file", *specFile)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := file.Write(spec.Bytes()); err != nil {
+			file.Close()
+			log.Fatal(err)
+		}
+		if err := file.Close(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Wrote the group spec to %s, set GROUP_SPEC_FILE=%s to manage the group from it\n", *specFile, *specFile)
+		return
+	}
+
+	// ...or static IPs, kept after the dynamic IP
+	var statics []string
+	for i, entry := range cidrs {
+		if i == dynamicIndex {
+			continue
+		}
+		cidr, err := normalizeCIDR(entry)
+		if err != nil {
+			log.Fatalf("Include entry %d: %v", i+1, err)
+		}
+		statics = append(statics, cidr)
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry run: would record static IPs: %s\n", strings.Join(statics, ", "))
+		return
+	}
+	var added []string
+	err = updateState(config.StateFile, func(state *State) error {
+		for _, cidr := range statics {
+			if !slices.Contains(state.StaticIPs, cidr) {
+				state.StaticIPs = append(state.StaticIPs, cidr)
+				added = append(added, cidr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(added) == 0 {
+		fmt.Println("No new static IPs, the state file already has every entry")
+		return
+	}
+	fmt.Printf("Recorded static IPs in %s: %s\n", config.StateFile, strings.Join(added, ", "))
+}
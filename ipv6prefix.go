@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// ipv6Providers only answer over IPv6, so they return an address within the delegated prefix even on dual-stack hosts
+var ipv6Providers = []ipProvider{
+	{URL: "https://api6.ipify.org?format=json", JsonPath: "ip"},
+	{URL: "https://ipv6.icanhazip.com"}, // Plain text
+	{URL: "https://v6.ident.me"},        // Plain text
+}
+
+// interfacePrefixProvider returns the provider reading a global IPv6 address from a local network interface,
+// e.g. the LAN interface of the router or of the host, which is numbered from the delegated prefix
+func interfacePrefixProvider(config Configuration) ipProvider {
+	return ipProvider{
+		URL:  "interface " + config.IPv6PrefixInterface,
+		Name: config.IPv6PrefixInterface,
+		Fetch: func(ctx context.Context) (string, error) {
+			return interfaceIPv6Address(config.IPv6PrefixInterface)
+		},
+	}
+}
+
+// interfaceIPv6Address returns the first public IPv6 address of the interface
+func interfaceIPv6Address(name string) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", fmt.Errorf("interface %s: %w", name, err)
+	}
+	if iface.Flags&net.FlagUp == 0 {
+		return "", fmt.Errorf("interface %s is down", name)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("interface %s: %w", name, err)
+	}
+
+	for _, addr := range addrs {
+		// Unique local (fd00::/8) and link-local addresses aren't part of the delegated prefix
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() == nil && isPublicIP(ipNet.IP) {
+			return ipNet.IP.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no public IPv6 address on interface %s", name)
+}
+
+// ipv6Prefix returns the prefix of the given length containing the IPv6 address, e.g. 2001:db8:12:3400::/56
+func ipv6Prefix(ip string, bits int) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", validationErrorf("invalid IP address %q", ip)
+	}
+	if !addr.Is6() || addr.Is4In6() {
+		return "", validationErrorf("IPv6 prefix mode needs an IPv6 address, got %s", ip)
+	}
+
+	prefix, err := addr.WithZone("").Prefix(bits)
+	if err != nil {
+		return "", err
+	}
+	return prefix.String(), nil
+}
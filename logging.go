@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// debugf logs a message only when LOG_LEVEL is debug
+func debugf(config Configuration, format string, args ...interface{}) {
+	if config.LogLevel == "debug" {
+		log.Printf("[DEBUG] "+format, args...)
+	}
+}
+
+// ANSI colors of the pretty log levels
+var logLevelColors = map[string]string{
+	"debug": "\033[90m",
+	"info":  "\033[36m",
+	"warn":  "\033[33m",
+	"error": "\033[31m",
+}
+
+// logWriter reformats the lines of the standard logger as pretty or JSON output, or as plain output
+// with the timestamp in another time zone
+type logWriter struct {
+	out      io.Writer
+	format   string
+	location *time.Location
+}
+
+// configureLogging switches the standard logger to a LOG_FORMAT, with timestamps in the location if any.
+// Pretty output is only used on a terminal, so redirected logs stay plain.
+func configureLogging(format string, location *time.Location) {
+	if format == "pretty" && !isTerminal(os.Stderr) {
+		format = "plain"
+	}
+	if format != "pretty" && format != "json" && location == nil {
+		log.SetFlags(log.LstdFlags)
+		log.SetOutput(os.Stderr)
+		return
+	}
+	if format != "pretty" && format != "json" {
+		format = "plain"
+	}
+
+	log.SetFlags(0)
+	log.SetOutput(&logWriter{out: os.Stderr, format: format, location: location})
+}
+
+// displayTime converts a timestamp to DISPLAY_TZ for humans, stored and scheduled times are left alone
+func displayTime(config Configuration, t time.Time) time.Time {
+	if config.DisplayLocation == nil {
+		return t
+	}
+	return t.In(config.DisplayLocation)
+}
+
+// isTerminal reports whether the file is an interactive terminal
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	now := time.Now()
+	if w.location != nil {
+		now = now.In(w.location)
+	}
+	level, message := logLevelOf(strings.TrimSuffix(string(p), "\n"))
+
+	var line string
+	switch w.format {
+	case "plain":
+		// Same layout as the standard logger
+		line = now.Format("2006/01/02 15:04:05 ") + string(p)
+	case "json":
+		data, err := json.Marshal(struct {
+			Time    string `json:"time"`
+			Level   string `json:"level"`
+			Message string `json:"msg"`
+		}{now.Format(time.RFC3339Nano), level, message})
+		if err != nil {
+			return 0, err
+		}
+		line = string(data) + "\n"
+	default:
+		// Continuation lines (state dumps, diffs) are indented under the message column
+		timestamp := now.Format("2006-01-02 15:04:05")
+		message = strings.ReplaceAll(message, "\n", "\n"+strings.Repeat(" ", len(timestamp)+7))
+		line = fmt.Sprintf("\033[2m%s\033[0m %s%-5s\033[0m %s\n", timestamp, logLevelColors[level], strings.ToUpper(level), message)
+	}
+
+	if _, err := io.WriteString(w.out, line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// logLevelOf derives the level of a log message from the conventions of the log calls,
+// stripping the level marker from the message
+func logLevelOf(message string) (level, text string) {
+	switch {
+	case strings.HasPrefix(message, "[DEBUG] "):
+		return "debug", strings.TrimPrefix(message, "[DEBUG] ")
+	case strings.HasPrefix(message, "Warning: "):
+		return "warn", strings.TrimPrefix(message, "Warning: ")
+	case strings.HasPrefix(message, "Error") || strings.HasPrefix(message, "Failed") || strings.Contains(message, " failed"):
+		return "error", message
+	}
+	return "info", message
+}
@@ -2,43 +2,34 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/containrrr/shoutrrr"
-	"github.com/joho/godotenv"
 	"github.com/robfig/cron/v3"
 )
 
-// Configuration holds environment variables
-type Configuration struct {
-	AccountID              string
-	RuleID                 string
-	CronSchedule           string
-	AuthToken              string
-	NotificationURL        string
-	NotificationIdentifier string
-	TestNotification       bool
-}
-
 // CloudflareResponse represents the response from Cloudflare API
 type CloudflareResponse struct {
 	Result struct {
-		ID      string `json:"id"`
-		Name    string `json:"name"`
-		UID     string `json:"uid"`
-		Include []struct {
-			IP struct {
-				IP string `json:"ip"`
-			} `json:"ip"`
-		} `json:"include"`
+		ID        string        `json:"id"`
+		Name      string        `json:"name"`
+		UID       string        `json:"uid"`
+		Include   []IncludeRule `json:"include"`
 		Require   []interface{} `json:"require"`
 		Exclude   []interface{} `json:"exclude"`
 		CreatedAt string        `json:"created_at"`
@@ -47,155 +38,316 @@ type CloudflareResponse struct {
 	Success  bool          `json:"success"`
 	Errors   []interface{} `json:"errors"`
 	Messages []interface{} `json:"messages"`
+
+	Raw []byte `json:"-"` // the response body as received
+}
+
+// IncludeRule is an IP range entry of an Access Group include list
+type IncludeRule struct {
+	IP struct {
+		IP string `json:"ip"`
+	} `json:"ip"`
 }
 
 // UpdateRequest represents the update payload for Cloudflare API
 type UpdateRequest struct {
-	Include []struct {
-		IP struct {
-			IP string `json:"ip"`
-		} `json:"ip"`
-	} `json:"include"`
+	Include []IncludeRule `json:"include"`
 }
 
-func loadConfig() Configuration {
-	accountID := os.Getenv("ACCOUNTID")
-	if accountID == "" {
-		log.Fatal("ACCOUNTID environment variable is not set")
+// ipRules builds include entries for a list of CIDRs
+func ipRules(cidrs []string) []IncludeRule {
+	rules := make([]IncludeRule, len(cidrs))
+	for i, cidr := range cidrs {
+		rules[i].IP.IP = cidr
 	}
+	return rules
+}
+
+// ipProvider describes a public IP lookup service
+type ipProvider struct {
+	URL      string
+	Name     string // Short name for notifications, the URL host when empty
+	JsonPath string // Empty for plain text response
+	Trace    bool   // Cloudflare cdn-cgi/trace key=value response
 
-	ruleID := os.Getenv("RULEID")
-	if ruleID == "" {
-		log.Fatal("RULEID environment variable is not set")
+	// Fetch replaces the HTTP lookup for providers with their own API, URL then only names the source
+	Fetch func(ctx context.Context) (string, error)
+}
+
+// List of IP service providers to try in order
+var ipProviders = []ipProvider{
+	{URL: "https://1.1.1.1/cdn-cgi/trace", Trace: true},
+	{URL: "https://api.ipify.org?format=json", JsonPath: "ip"},
+	{URL: "https://api.my-ip.io/ip.json", JsonPath: "ip"},
+	{URL: "https://ifconfig.me/all.json", JsonPath: "ip_addr"},
+	{URL: "https://ipinfo.io/json", JsonPath: "ip"},
+	{URL: "https://api.myip.com", JsonPath: "ip"},
+	{URL: "https://ifconfig.co/json", JsonPath: "ip"},
+	{URL: "https://ip.seeip.org/jsonip", JsonPath: "ip"},
+	{URL: "https://icanhazip.com"},    // Plain text
+	{URL: "https://ifconfig.me"},      // Plain text
+	{URL: "https://ipecho.net/plain"}, // Plain text
+}
+
+// getCurrentIP detects the public IP, also returning the name of the provider that answered
+func getCurrentIP(ctx context.Context, config Configuration) (ip string, source string, err error) {
+	client := newHTTPClient(config, config.ProviderTimeout) // Set timeout to avoid hanging
+
+	// Ride out a brief outage of the uplink with further passes over the providers
+	backoff := config.ProviderPassBackoff
+	for pass := 1; ; pass++ {
+		ip, source, err = detectIPPass(ctx, config, client)
+		if err == nil || pass >= config.ProviderPasses || ctx.Err() != nil {
+			return ip, source, err
+		}
+
+		// A pass that can't finish within RUN_TIMEOUT would only delay the error
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff+config.ProviderTimeout {
+			log.Printf("Every IP provider failed, no time left for another pass within RUN_TIMEOUT")
+			return ip, source, err
+		}
+		log.Printf("Every IP provider failed, starting pass %d of %d in %s: %v", pass+1, config.ProviderPasses, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return "", "", fmt.Errorf("IP detection aborted: %w, last error: %v", ctx.Err(), err)
+		}
+		backoff *= 2
 	}
+}
 
-	cronSchedule := os.Getenv("CRON")
-	if cronSchedule == "" {
-		log.Fatal("CRON environment variable is not set")
+// detectIPPass tries every available provider once, with PROVIDER_RETRIES quick retries each
+func detectIPPass(ctx context.Context, config Configuration, client *http.Client) (ip string, source string, err error) {
+	var lastError error
+	for _, provider := range availableProviders(config, providersFor(config)) {
+		if ctx.Err() != nil {
+			return "", "", fmt.Errorf("IP detection aborted: %w, last error: %v", ctx.Err(), lastError)
+		}
+
+		backoff := config.ProviderRetryBackoff
+
+		// Retry the same provider a few times before failing over to the next one
+		for attempt := 0; attempt <= config.ProviderRetries; attempt++ {
+			if attempt > 0 {
+				log.Printf("Retrying %s in %s (attempt %d of %d)", provider.URL, backoff, attempt, config.ProviderRetries)
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return "", "", fmt.Errorf("IP detection aborted: %w, last error: %v", ctx.Err(), lastError)
+				}
+				backoff *= 2
+			}
+
+			start := time.Now()
+			ip, err := fetchIPFromProvider(ctx, client, provider)
+			recordProviderResult(config, provider.URL, time.Since(start), err)
+			if err == nil {
+				return ip, provider.name(), nil
+			}
+			lastError = err
+		}
 	}
 
-	authToken := os.Getenv("AUTH_TOKEN")
-	if authToken == "" {
-		log.Fatal("AUTH_TOKEN environment variable is not set")
+	return "", "", fmt.Errorf("%w, last error: %w", ErrNoProviderAvailable, lastError)
+}
+
+// name returns the short provider name shown in notifications
+func (p ipProvider) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	if u, err := url.Parse(p.URL); err == nil && u.Hostname() != "" {
+		return u.Hostname()
 	}
+	return p.URL
+}
 
-	// Optional: Notification URL (using Shoutrrr URL format)
-	notificationURL := os.Getenv("NOTIFICATION_URL")
+// providersFor returns the providers to try, starting with the UniFi gateway and the configured zone's trace endpoint if any,
+// or only the Tailscale device when one is tracked
+func providersFor(config Configuration) []ipProvider {
+	// The tracked tailnet device is usually elsewhere, so lookups of our own IP would be wrong
+	if config.TailscaleDevice != "" {
+		return []ipProvider{tailscaleProvider(config)}
+	}
 
-	// Optional: Notification URL (using Shoutrrr URL format)
-	notificationIdentifier := os.Getenv("NOTIFICATION_IDENTIFIER")
+	// A serverless function's own egress IP says nothing about the monitored site, only its gateway does
+	if config.Serverless {
+		return []ipProvider{unifiProvider(config)}
+	}
 
-	// Test notification on startup (optional)
-	testNotification := false
-	if os.Getenv("TEST_NOTIFICATION") == "true" {
-		testNotification = true
+	// The delegated prefix is only seen on IPv6: a local interface, or lookups that can't fall back to IPv4
+	if config.IPv6PrefixLength > 0 {
+		if config.IPv6PrefixInterface != "" {
+			return []ipProvider{interfacePrefixProvider(config)}
+		}
+		return ipv6Providers
 	}
 
-	return Configuration{
-		AccountID:              accountID,
-		RuleID:                 ruleID,
-		CronSchedule:           cronSchedule,
-		AuthToken:              authToken,
-		NotificationURL:        notificationURL,
-		NotificationIdentifier: notificationIdentifier,
-		TestNotification:       testNotification,
+	var providers []ipProvider
+	if config.UniFiURL != "" {
+		providers = append(providers, unifiProvider(config))
+	}
+	if config.TraceZone != "" {
+		providers = append(providers, ipProvider{URL: fmt.Sprintf("https://%s/cdn-cgi/trace", config.TraceZone), Trace: true})
 	}
+	return append(providers, ipProviders...)
 }
 
-func getCurrentIP() (string, error) {
-	// List of IP service providers to try in order
-	ipProviders := []struct {
-		URL      string
-		JsonPath string // Empty for plain text response
-	}{
-		{"https://api.ipify.org?format=json", "ip"},
-		{"https://api.my-ip.io/ip.json", "ip"},
-		{"https://ifconfig.me/all.json", "ip_addr"},
-		{"https://ipinfo.io/json", "ip"},
-		{"https://api.myip.com", "ip"},
-		{"https://ifconfig.co/json", "ip"},
-		{"https://ip.seeip.org/jsonip", "ip"},
-		{"https://icanhazip.com", ""},    // Plain text
-		{"https://ifconfig.me", ""},      // Plain text
-		{"https://ipecho.net/plain", ""}, // Plain text
+// fetchIPFromProvider queries a single IP provider and extracts the IP from its response
+func fetchIPFromProvider(ctx context.Context, client *http.Client, provider ipProvider) (string, error) {
+	log.Printf("Trying to get IP from: %s", provider.URL)
+
+	if provider.Fetch != nil {
+		ip, err := provider.Fetch(ctx)
+		if err != nil {
+			log.Printf("Failed to get IP from %s: %v", provider.URL, err)
+			return "", err
+		}
+		log.Printf("Successfully obtained IP from %s", provider.URL)
+		return ip, nil
 	}
 
-	var lastError error
-	client := &http.Client{
-		Timeout: 5 * time.Second, // Set timeout to avoid hanging
+	req, err := http.NewRequestWithContext(ctx, "GET", provider.URL, nil)
+	if err != nil {
+		return "", err
 	}
 
-	for _, provider := range ipProviders {
-		log.Printf("Trying to get IP from: %s", provider.URL)
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Failed to get IP from %s: %v", provider.URL, err)
+		return "", err
+	}
 
-		resp, err := client.Get(provider.URL)
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
 		if err != nil {
-			log.Printf("Failed to get IP from %s: %v", provider.URL, err)
-			lastError = err
-			continue
+			log.Printf("Failed to close response body from %s: %v", provider.URL, err)
 		}
+	}(resp.Body)
 
-		defer func(Body io.ReadCloser) {
-			err := Body.Close()
-			if err != nil {
-				log.Printf("Failed to close response body from %s: %v", provider.URL, err)
-			}
-		}(resp.Body)
-
-		// Check if we got a successful response
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			log.Printf("Failed to get IP from %s: Status %d, Body: %s", provider.URL, resp.StatusCode, string(bodyBytes))
-			lastError = fmt.Errorf("HTTP error: %d", resp.StatusCode)
-			continue
-		}
-
-		// Handle JSON response
-		if provider.JsonPath != "" {
-			var result map[string]interface{}
-			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-				log.Printf("Failed to decode JSON from %s: %v", provider.URL, err)
-				lastError = err
-				continue
-			}
+	// Check if we got a successful response
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		log.Printf("Failed to get IP from %s: Status %d, Body: %s", provider.URL, resp.StatusCode, string(bodyBytes))
+		return "", &StatusError{Message: fmt.Sprintf("HTTP error: %d", resp.StatusCode), StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	}
 
-			// Extract IP from the specified JSON path
-			if ipValue, ok := result[provider.JsonPath]; ok {
-				if ipStr, ok := ipValue.(string); ok && ipStr != "" {
-					log.Printf("Successfully obtained IP from %s", provider.URL)
-					return ipStr, nil
-				}
-			}
+	// Handle JSON response
+	if provider.JsonPath != "" {
+		var result map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			log.Printf("Failed to decode JSON from %s: %v", provider.URL, err)
+			return "", err
+		}
 
-			lastError = fmt.Errorf("could not find IP in JSON response from %s", provider.URL)
-			continue
-		} else {
-			// Handle plain text response
-			bodyBytes, err := io.ReadAll(resp.Body)
-			if err != nil {
-				log.Printf("Failed to read response from %s: %v", provider.URL, err)
-				lastError = err
-				continue
+		// Extract IP from the specified JSON path
+		if ipValue, ok := result[provider.JsonPath]; ok {
+			if ipStr, ok := ipValue.(string); ok && ipStr != "" {
+				log.Printf("Successfully obtained IP from %s", provider.URL)
+				return ipStr, nil
 			}
+		}
+
+		return "", fmt.Errorf("could not find IP in JSON response from %s", provider.URL)
+	}
 
-			ip := strings.TrimSpace(string(bodyBytes))
-			// Basic validation: check that we have something that looks like an IP
-			if ip != "" && strings.Contains(ip, ".") {
+	// Handle Cloudflare trace response
+	if provider.Trace {
+		bodyBytes, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Printf("Failed to read response from %s: %v", provider.URL, err)
+			return "", err
+		}
+
+		for _, line := range strings.Split(string(bodyBytes), "\n") {
+			if ip, ok := strings.CutPrefix(strings.TrimSpace(line), "ip="); ok && net.ParseIP(ip) != nil {
 				log.Printf("Successfully obtained IP from %s", provider.URL)
 				return ip, nil
 			}
+		}
+
+		return "", fmt.Errorf("could not find IP in trace response from %s", provider.URL)
+	}
+
+	// Handle plain text response
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("Failed to read response from %s: %v", provider.URL, err)
+		return "", err
+	}
 
-			lastError = fmt.Errorf("received invalid IP from %s: %s", provider.URL, ip)
+	ip := strings.TrimSpace(string(bodyBytes))
+	// Basic validation: check that we have something that looks like an IPv4 or IPv6 address
+	if ip != "" && strings.ContainsAny(ip, ".:") {
+		log.Printf("Successfully obtained IP from %s", provider.URL)
+		return ip, nil
+	}
+
+	return "", fmt.Errorf("received invalid IP from %s: %s", provider.URL, ip)
+}
+
+// verifyCloudflareToken checks that the Cloudflare API is reachable and the token is active
+func verifyCloudflareToken(ctx context.Context, config Configuration) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", config.APIBaseURL+"/user/tokens/verify", nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Authorization", "Bearer "+config.AuthToken)
+
+	client := newCloudflareClient(config)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare API unreachable: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Printf("Failed to close response body: %v", err)
 		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Message: fmt.Sprintf("token verification failed with status %d", resp.StatusCode), StatusCode: resp.StatusCode}
+	}
+
+	var verifyResponse struct {
+		Result struct {
+			Status string `json:"status"`
+		} `json:"result"`
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&verifyResponse); err != nil {
+		return err
+	}
+
+	if !verifyResponse.Success || verifyResponse.Result.Status != "active" {
+		return fmt.Errorf("token is not active (status: %q)", verifyResponse.Result.Status)
+	}
+
+	return nil
+}
+
+func getCloudflareGroup(ctx context.Context, config Configuration) (*CloudflareResponse, error) {
+	body, err := fetchCloudflareGroup(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfResponse CloudflareResponse
+	if err := json.Unmarshal(body, &cfResponse); err != nil {
+		return nil, err
 	}
+	cfResponse.Raw = body
 
-	return "", fmt.Errorf("all IP providers failed, last error: %v", lastError)
+	return &cfResponse, nil
 }
 
-func getCloudflareGroup(config Configuration) (*CloudflareResponse, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/access/groups/%s", config.AccountID, config.RuleID)
+// fetchCloudflareGroup returns the raw Cloudflare API response for the Access Group
+func fetchCloudflareGroup(ctx context.Context, config Configuration) ([]byte, error) {
+	url := fmt.Sprintf("%s/accounts/%s/access/groups/%s", config.APIBaseURL, config.AccountID, config.RuleID)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -203,7 +355,7 @@ func getCloudflareGroup(config Configuration) (*CloudflareResponse, error) {
 	req.Header.Add("Authorization", "Bearer "+config.AuthToken)
 	req.Header.Add("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := newCloudflareClient(config)
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
@@ -217,53 +369,66 @@ func getCloudflareGroup(config Configuration) (*CloudflareResponse, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get Cloudflare group: %s, status: %d", string(bodyBytes), resp.StatusCode)
+		return nil, &StatusError{
+			Message:    fmt.Sprintf("failed to get Cloudflare group: %s, status: %d", string(bodyBytes), resp.StatusCode),
+			StatusCode: resp.StatusCode,
+			Body:       string(bodyBytes),
+			NotFound:   ErrGroupNotFound,
+		}
 	}
 
-	var cfResponse CloudflareResponse
-	if err := json.NewDecoder(resp.Body).Decode(&cfResponse); err != nil {
+	return io.ReadAll(resp.Body)
+}
+
+// updateCloudflareGroup sets the dynamic IPs as the first include entries, followed by the static IPs from the state file
+func updateCloudflareGroup(ctx context.Context, config Configuration, newIPs ...string) ([]byte, error) {
+	state, err := loadState(config.StateFile)
+	if err != nil {
 		return nil, err
 	}
 
-	return &cfResponse, nil
+	var cidrs []string
+	for _, ip := range newIPs {
+		cidrs = append(cidrs, ipToCIDR(ip))
+	}
+	return putCloudflareGroupIPs(ctx, config, append(cidrs, state.StaticIPs...))
 }
 
-func updateCloudflareGroup(config Configuration, newIP string) error {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/access/groups/%s", config.AccountID, config.RuleID)
-
-	updateReq := UpdateRequest{
-		Include: []struct {
-			IP struct {
-				IP string `json:"ip"`
-			} `json:"ip"`
-		}{
-			{
-				IP: struct {
-					IP string `json:"ip"`
-				}{
-					IP: newIP + "/32",
-				},
-			},
-		},
-	}
-
-	jsonData, err := json.Marshal(updateReq)
+// putCloudflareGroupIPs replaces the include list of the Access Group with the given CIDRs,
+// returning the updated group as sent back by Cloudflare (nil in dry run)
+func putCloudflareGroupIPs(ctx context.Context, config Configuration, cidrs []string) ([]byte, error) {
+	if config.DryRun {
+		log.Printf("Dry run: would update Cloudflare Access Group %s with: %s", config.RuleID, strings.Join(cidrs, ", "))
+		return nil, nil
+	}
+
+	return putCloudflareGroup(ctx, config, UpdateRequest{Include: ipRules(cidrs)})
+}
+
+// putCloudflareGroup sends an update of the Access Group, returning the group as sent back by Cloudflare
+func putCloudflareGroup(ctx context.Context, config Configuration, update interface{}) ([]byte, error) {
+	// Whatever the outcome, the last verified IP may no longer be in the group
+	forgetPublishedIPs(config, config.RuleID)
+
+	url := fmt.Sprintf("%s/accounts/%s/access/groups/%s", config.APIBaseURL, config.AccountID, config.RuleID)
+
+	jsonData, err := json.Marshal(update)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req.Header.Add("Authorization", "Bearer "+config.AuthToken)
 	req.Header.Add("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := newCloudflareClient(config)
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -274,94 +439,193 @@ func updateCloudflareGroup(config Configuration, newIP string) error {
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to update Cloudflare group: %s, status: %d", string(bodyBytes), resp.StatusCode)
+		return nil, &StatusError{
+			Message:    fmt.Sprintf("failed to update Cloudflare group: %s, status: %d", string(bodyBytes), resp.StatusCode),
+			StatusCode: resp.StatusCode,
+			Body:       string(bodyBytes),
+			NotFound:   ErrGroupNotFound,
+		}
 	}
 
-	return nil
+	return io.ReadAll(resp.Body)
+}
+
+// ipToCIDR returns the single-address CIDR for an IP (/32 for IPv4, /128 for IPv6), keeping prefixes as they are
+func ipToCIDR(ip string) string {
+	if strings.Contains(ip, "/") {
+		return ip
+	}
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
+
+// cidrToIP strips the single-address suffix from a CIDR (/32 for IPv4, /128 for IPv6), keeping prefixes
+// such as an IPv6 /32 as they are
+func cidrToIP(cidr string) string {
+	ip, bits, found := strings.Cut(cidr, "/")
+	parsed := net.ParseIP(ip)
+	if !found || parsed == nil {
+		return cidr
+	}
+	if (parsed.To4() != nil && bits == "32") || (parsed.To4() == nil && bits == "128") {
+		return ip
+	}
+	return cidr
 }
 
 // sendNotification sends a notification using Shoutrrr if configured
-func sendNotification(config Configuration, message string) error {
+func sendNotification(config Configuration, severity notificationSeverity, message string) error {
 	if config.NotificationURL == "" {
 		log.Println("Notification URL not configured, skipping notification")
 		return nil
 	}
 
+	// Sent together with the other Access Groups at the end of the run
+	if config.notifications != nil {
+		config.notifications.add(config.RuleID, severity, message)
+		return nil
+	}
+
 	log.Printf("Sending notification: %s", message)
 
+	// Make it obvious that nothing was actually changed
+	prefix := fmt.Sprintf("%s: ", config.NotificationIdentifier)
+	if config.DryRun {
+		prefix += tr(config, "[DRY RUN] ")
+	}
+
+	// Some services reject long messages, e.g. when an error body is embedded
+	if maxLength := notificationMaxLength(config); maxLength > 0 {
+		message = truncateNotification(message, max(maxLength-len([]rune(prefix)), 1))
+	}
+
+	// Title, priority and other options of the service, chosen before the identifier is added
+	params := notificationParams(config, severity)
+
 	// Adding Identifier to the message
-	msg := fmt.Sprintf("%s: %s", config.NotificationIdentifier, message)
+	msg := prefix + message
 
-	err := shoutrrr.Send(config.NotificationURL, msg)
+	// shoutrrr has no per-call timeout, so bound the send ourselves
+	result := make(chan error, 1)
+	go func() {
+		result <- sendShoutrrr(config.NotificationURL, msg, params)
+	}()
+
+	var err error
+	if config.NotificationTimeout > 0 {
+		select {
+		case err = <-result:
+		case <-time.After(config.NotificationTimeout):
+			err = fmt.Errorf("timed out after %s", config.NotificationTimeout)
+		}
+	} else {
+		err = <-result
+	}
+	recordComponent(ComponentNotifications, err)
 	if err != nil {
-		return fmt.Errorf("failed to send notification: %v", err)
+		err = fmt.Errorf("failed to send notification: %w", err)
+		recordError(ErrorCategoryNotification, err)
+		return err
 	}
 
 	log.Println("Notification sent successfully")
 	return nil
 }
 
-// startHealthCheckServer starts a simple HTTP server for container health checks
-func startHealthCheckServer(port string) {
-	// Check if the port is empty
-	if port == "" {
-		port = "8080"
-	}
+// Global variable to track application start time
+var startTime time.Time
 
-	// Define a simple handler for health checks
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, err := w.Write([]byte("OK"))
-		if err != nil {
-			return
-		}
-	})
+// activeConfig holds the current configuration, which may be replaced at runtime by a config backend reload
+var activeConfig atomic.Pointer[Configuration]
 
-	// Define a handler for readiness checks that provides more details
-	http.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
-		info := map[string]interface{}{
-			"status":    "OK",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"uptime":    time.Since(startTime).String(),
-		}
+// configMutex serializes the runtime replacements of activeConfig, so a config backend reload that read
+// the old token can't store it after a token rotation
+var configMutex sync.Mutex
 
-		jsonData, err := json.Marshal(info)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+// runMutex prevents scheduled runs and pre-flight retries from overlapping
+var runMutex sync.Mutex
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_, err = w.Write(jsonData)
-		if err != nil {
-			return
-		}
-	})
+// preflightRetryPending is set while a faster retry after a failed pre-flight check is scheduled
+var preflightRetryPending atomic.Bool
 
-	// Start the HTTP server in a goroutine
-	go func() {
-		addr := fmt.Sprintf(":%s", port)
-		log.Printf("Starting health check server on %s", addr)
-		if err := http.ListenAndServe(addr, nil); err != nil {
-			log.Printf("Health check server error: %v", err)
+// checkAndUpdateIP runs a single check, updating the Access Group if the IP changed, and reports what happened
+func checkAndUpdateIP(config Configuration) (result RunResult) {
+	if !runMutex.TryLock() {
+		log.Println("Previous check is still running, skipping this one")
+		result.Action = ActionSkipped
+		return
+	}
+	defer runMutex.Unlock()
+
+	start := time.Now()
+	checkStartedAt.Store(&start)
+	result.DryRun = config.DryRun
+	defer func() {
+		checkStartedAt.Store(nil)
+		result.Duration = time.Since(start)
+		lastRunResult.Store(&lastRun{At: start, Result: result})
+		recordSessionRun(result)
+		recordCounters(config, result, start)
+		notifyTokenFailover(config)
+		if result.Action == ActionUpdated {
+			lastUpdateAt.Store(&start)
 		}
 	}()
-}
 
-// Global variable to track application start time
-var startTime time.Time
+	// Respect a manual override set through the API
+	if until, ok := manualHoldActive(); ok {
+		log.Printf("Manual IP override active until %s, skipping automatic update", displayTime(config, until).Format(time.RFC3339))
+		result.Action = ActionSkipped
+		return
+	}
 
-func checkAndUpdateIP(config Configuration) {
 	log.Println("Checking if IP update is needed...")
 
-	// Get current public IP
-	currentIP, err := getCurrentIP()
+	// Bound the whole run so slow providers and API calls can never spill into the next one
+	ctx, cancel := context.WithTimeout(context.Background(), config.RunTimeout)
+	defer cancel()
+
+	// Make sure Cloudflare is usable before spending time on IP detection
+	if config.PreflightCheck {
+		if err := verifyCloudflareToken(ctx, config); err != nil {
+			log.Printf("Pre-flight check failed, skipping IP detection: %v", err)
+			result.fail(ErrorCategoryPreflight, fmt.Errorf("pre-flight check failed: %w", err))
+			schedulePreflightRetry(config)
+			return
+		}
+	}
+
+	// Publish every uplink of a multi-WAN site
+	if config.MultiWAN {
+		updateWANIPs(ctx, config, &result)
+		return
+	}
+
+	// Get current public IP, unless a simulated one was injected for testing
+	var currentIP string
+	var err error
+	detectionStart := time.Now()
+	if config.SimulateIP != "" {
+		log.Printf("Using simulated IP: %s", config.SimulateIP)
+		currentIP = config.SimulateIP
+		result.Provider = "simulated"
+	} else if config.PushedIP != "" {
+		log.Printf("Using IP pushed by the site: %s", config.PushedIP)
+		currentIP = config.PushedIP
+		result.Provider = "pushed"
+	} else {
+		currentIP, result.Provider, err = getCurrentIP(ctx, config)
+	}
+	result.DetectionTime = time.Since(detectionStart)
+	recordComponent(ComponentIPDetection, err)
 	if err != nil {
 		log.Printf("Error getting current IP: %v", err)
+		result.fail(ErrorCategoryIPDetection, err)
 		// Notify about error
 		if config.NotificationURL != "" {
-			err := sendNotification(config, fmt.Sprintf("❌ Error getting current IP: %v", err))
+			err := sendNotification(config, SeverityError, tr(config, "❌ Error getting current IP: %v", err))
 			if err != nil {
 				return
 			}
@@ -371,13 +635,66 @@ func checkAndUpdateIP(config Configuration) {
 	currentIP = strings.TrimSpace(currentIP)
 	log.Printf("Current public IP: %s", currentIP)
 
+	// Publish the delegated prefix rather than the address of this host
+	if config.IPv6PrefixLength > 0 {
+		prefix, err := ipv6Prefix(currentIP, config.IPv6PrefixLength)
+		if err != nil {
+			log.Printf("Error getting the delegated IPv6 prefix: %v", err)
+			recordComponent(ComponentIPDetection, err)
+			result.fail(ErrorCategoryIPDetection, err)
+			if config.NotificationURL != "" {
+				if err := sendNotification(config, SeverityError, tr(config, "❌ Error getting current IP: %v", err)); err != nil {
+					return
+				}
+			}
+			return
+		}
+		log.Printf("Delegated IPv6 prefix: %s", prefix)
+		currentIP = prefix
+	}
+
+	// Don't ping-pong the group while the detected IP oscillates
+	if detectFlapping(config, currentIP) {
+		result.Action = ActionFlapping
+		return
+	}
+	result.NewIP = currentIP
+
+	// Publish the IP in every target Access Group
+	result = runTargets(config, result, func(target Configuration, targetResult *RunResult) {
+		updateGroupIP(ctx, target, currentIP, targetResult)
+	})
+	return
+}
+
+// updateGroupIP makes the detected IP the first include entry of the Access Group, if it changed
+func updateGroupIP(ctx context.Context, config Configuration, currentIP string, result *RunResult) {
+	// The whole group is declared in the spec file
+	if config.GroupSpecFile != "" {
+		reconcileGroupSpec(ctx, config, currentIP, result)
+		return
+	}
+
+	// Save the lookup while the IP is the one recently published
+	if verifiedAt, ok := recentlyVerified(config, currentIP); ok {
+		log.Printf("IP matches the one verified in the Access Group at %s, skipping the lookup", displayTime(config, verifiedAt).Format(time.RFC3339))
+		result.PreviousIP = currentIP
+		result.Action = ActionNoChange
+		clearPendingIP(config)
+		return
+	}
+
 	// Get Cloudflare Access Group
-	cfGroup, err := getCloudflareGroup(config)
+	cfGroup, err := getCloudflareGroup(ctx, config)
 	if err != nil {
 		log.Printf("Error getting Cloudflare Access Group: %v", err)
+		if errors.Is(err, ErrGroupNotFound) {
+			log.Println("Check that ACCOUNTID and RULEID point to an existing Access Group the token can read")
+		}
+		result.fail(ErrorCategoryCloudflare, err)
 		// Notify about error
 		if config.NotificationURL != "" {
-			err := sendNotification(config, fmt.Sprintf("❌ Error getting Cloudflare Access Group: %v", err))
+			err := sendNotification(config, SeverityError, tr(config, "❌ Error getting Cloudflare Access Group: %v", err))
 			if err != nil {
 				return
 			}
@@ -388,21 +705,27 @@ func checkAndUpdateIP(config Configuration) {
 	// Check if there's at least one IP in the include list
 	if len(cfGroup.Result.Include) == 0 || cfGroup.Result.Include[0].IP.IP == "" {
 		log.Println("No IP found in Cloudflare Access Group, updating...")
-		err = updateCloudflareGroup(config, currentIP)
+		updateStart := time.Now()
+		updated, err := updateCloudflareGroup(ctx, config, currentIP)
+		result.UpdateTime = time.Since(updateStart)
 		if err != nil {
 			log.Printf("Error updating Cloudflare Access Group: %v", err)
+			result.fail(ErrorCategoryCloudflare, err)
 			// Notify about error
 			if config.NotificationURL != "" {
-				err := sendNotification(config, fmt.Sprintf("❌ Error updating Cloudflare Access Group: %v", err))
+				err := sendNotification(config, SeverityError, tr(config, "❌ Error updating Cloudflare Access Group: %v", err))
 				if err != nil {
 					return
 				}
 			}
 		} else {
 			log.Printf("Successfully updated Cloudflare Access Group with IP: %s", currentIP)
+			result.Action = ActionUpdated
+			rememberPublishedIP(config, currentIP)
+			diff := logGroupDiff(config, cfGroup.Raw, updated)
 			// Notify about successful update
 			if config.NotificationURL != "" {
-				err := sendNotification(config, fmt.Sprintf("✅ Initial IP set in Cloudflare Access Group: %s", currentIP))
+				err := sendNotification(config, SeverityInfo, withGroupDiff(config, withTimings(config, tr(config, "✅ Initial IP set in Cloudflare Access Group: %s", currentIP), *result), diff))
 				if err != nil {
 					return
 				}
@@ -411,62 +734,220 @@ func checkAndUpdateIP(config Configuration) {
 		return
 	}
 
-	// Get the IP from Cloudflare (remove /32 suffix if present)
-	cfIP := cfGroup.Result.Include[0].IP.IP
-	cfIP = strings.TrimSuffix(cfIP, "/32")
+	// Get the IP from Cloudflare (remove /32 or /128 suffix if present)
+	cfIP := cidrToIP(cfGroup.Result.Include[0].IP.IP)
 	log.Printf("Cloudflare Access Group IP: %s", cfIP)
+	result.PreviousIP = cfIP
 
 	// Compare IPs
+	if currentIP != cfIP && !confirmNewIP(ctx, config, currentIP, result.Provider) {
+		result.Action = ActionPending
+		return
+	}
 	if currentIP != cfIP {
 		log.Printf("IP mismatch detected. Updating Cloudflare Access Group from %s to %s", cfIP, currentIP)
-		err = updateCloudflareGroup(config, currentIP)
+		updateStart := time.Now()
+		updated, err := updateCloudflareGroup(ctx, config, currentIP)
+		result.UpdateTime = time.Since(updateStart)
 		if err != nil {
 			log.Printf("Error updating Cloudflare Access Group: %v", err)
+			result.fail(ErrorCategoryCloudflare, err)
 			// Notify about error
 			if config.NotificationURL != "" {
-				err := sendNotification(config, fmt.Sprintf("❌ Failed to update IP from %s to %s: %v", cfIP, currentIP, err))
+				err := sendNotification(config, SeverityError, tr(config, "❌ Failed to update IP from %s to %s: %v", cfIP, currentIP, err))
 				if err != nil {
 					return
 				}
 			}
 		} else {
 			log.Printf("Successfully updated Cloudflare Access Group with IP: %s", currentIP)
+			result.Action = ActionUpdated
+			rememberPublishedIP(config, currentIP)
+			diff := logGroupDiff(config, cfGroup.Raw, updated)
 			// Notify about successful update
 			if config.NotificationURL != "" {
-				err := sendNotification(config, fmt.Sprintf("🔄 IP Address Updated: %s ➡️ %s", cfIP, currentIP))
+				err := sendNotification(config, SeverityInfo, withGroupDiff(config, withTimings(config, tr(config, "🔄 IP Address Updated: %s ➡️ %s", cfIP, currentIP), *result), diff))
 				if err != nil {
 					return
 				}
 			}
 		}
+	} else if restoreGroupInclude(ctx, config, cfGroup, currentIP, result) {
+		// The other include entries had drifted and were rewritten
+		return
 	} else {
 		log.Println("IP is already up to date, no action needed")
+		result.Action = ActionNoChange
+		clearPendingIP(config)
+		rememberPublishedIP(config, currentIP)
+	}
+
+}
+
+// waitForRunningCheck stops the scheduler and waits up to timeout for a running check or API update to finish.
+// It keeps runMutex locked afterwards, so pre-flight retries and API calls can't start a new one.
+func waitForRunningCheck(c *cron.Cron, timeout time.Duration) bool {
+	c.Stop()
+	if runMutex.TryLock() {
+		return true
+	}
+
+	log.Printf("Waiting up to %s for the running check to finish...", timeout)
+	locked := make(chan struct{})
+	go func() {
+		runMutex.Lock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// schedulePreflightRetry runs another check sooner than the cron schedule after a failed pre-flight check
+func schedulePreflightRetry(config Configuration) {
+	if config.PreflightRetryInterval == 0 || !preflightRetryPending.CompareAndSwap(false, true) {
+		return
 	}
+
+	log.Printf("Retrying in %s", config.PreflightRetryInterval)
+	time.AfterFunc(config.PreflightRetryInterval, func() {
+		preflightRetryPending.Store(false)
+		checkAndUpdateIP(*activeConfig.Load())
+	})
 }
 
 func main() {
 	// Initialize the start time for uptime tracking
 	startTime = time.Now()
 
-	log.Println("Cloudflare Access Group IP Updater")
+	// The Lambda custom runtime starts the binary without arguments
+	if len(os.Args) == 1 && os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
+		runServerless(nil)
+		return
+	}
 
-	// Load the.env file if it exists
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found or error loading it. Using environment variables directly.")
-	} else {
-		log.Println("Successfully loaded .env file")
+	// Subcommands that don't need the updater configuration
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "mock-server":
+			runMockServer(os.Args[2:])
+			return
+		case "set-ip":
+			runSetIP(os.Args[2:])
+			return
+		case "plan":
+			runPlan(os.Args[2:])
+			return
+		case "show-group":
+			runShowGroup(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "rotate-token":
+			runRotateToken(os.Args[2:])
+			return
+		case "notify-test":
+			runNotifyTest(os.Args[2:])
+			return
+		case "static":
+			runStatic(os.Args[2:])
+			return
+		case "list-accounts":
+			runListAccounts(os.Args[2:])
+			return
+		case "list-groups":
+			runListGroups(os.Args[2:])
+			return
+		case "serverless":
+			runServerless(os.Args[2:])
+			return
+		case "schema":
+			if err := runSchema(); err != nil {
+				log.Fatalf("Failed to write schema: %v", err)
+			}
+			return
+		}
+	}
+
+	simulateIP := flag.String("simulate-ip", "", "Use this IP instead of detecting it, to test the update and notification pipeline")
+	dryRun := flag.Bool("dry-run", false, "Log the changes that would be made to Cloudflare without applying them")
+	once := flag.Bool("once", false, "Run a single check and exit (exit code 0 = no change, 2 = updated, 1 = error)")
+	output := flag.String("output", "text", "Result format for --once: text, json, or template=<Go template> (e.g. template='{{.NewIP}}')")
+	profile := flag.String("profile", "", "Named configuration profile to use (reads PROFILE_<NAME>_<KEY> before <KEY>, defaults to PROFILE)")
+	flag.Parse()
+
+	// Until the configuration is loaded, follow LOG_FORMAT from the environment
+	configureLogging(strings.ToLower(os.Getenv("LOG_FORMAT")), nil)
+	log.Printf("Cloudflare Access Group IP Updater %s", currentBuildInfo())
+
+	// Load the .env file(s), the profile and the remote configuration backend
+	backend := initConfigSources(*profile)
+
+	// buildConfig loads the configuration and applies the command line overrides
+	buildConfig := func() (Configuration, error) {
+		config, err := loadConfig()
+		if err != nil {
+			return config, err
+		}
+		if *dryRun {
+			config.DryRun = true
+		}
+		if *simulateIP != "" {
+			if net.ParseIP(*simulateIP) == nil {
+				return config, validationErrorf("--simulate-ip must be a valid IP address, got %q", *simulateIP)
+			}
+			config.SimulateIP = *simulateIP
+		}
+		return config, nil
+	}
+
+	config, err := buildConfig()
+	if err == nil {
+		err = resolveConfigAccount(&config)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.SimulateIP != "" && !config.DryRun {
+		log.Println("Warning: --simulate-ip without --dry-run will write the simulated IP to Cloudflare")
 	}
+	activeConfig.Store(&config)
+	loadCounters(config)
+	log.Printf("Configuration fingerprint %s, features: %s", config.Fingerprint, strings.Join(enabledFeatures(config), ", "))
 
-	// Load configuration
-	config := loadConfig()
+	// Show human-facing timestamps (logs, /ready, notifications) in the configured time zone,
+	// while the cron schedule keeps using the system one
+	configureLogging(config.LogFormat, config.DisplayLocation)
+
+	// Run a single check and exit with a status reflecting the outcome
+	if *once {
+		printResult, err := newResultPrinter(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		result := checkAndUpdateIP(config)
+		if err := printResult(result); err != nil {
+			log.Printf("Failed to print result: %v", err)
+		}
+		os.Exit(result.ExitCode())
+	}
 
 	// Start the health check server
-	startHealthCheckServer("8080")
+	startHealthCheckServer(config, "8080")
 
 	// Send test notification if requested
 	if config.TestNotification && config.NotificationURL != "" {
 		log.Println("Sending test notification...")
-		err := sendNotification(config, "🚀 Cloudflare IP Updater started - Test notification")
+		err := sendNotification(config, SeverityInfo, tr(config, "🚀 Cloudflare IP Updater started - Test notification"))
 		if err != nil {
 			log.Printf("Test notification failed: %v", err)
 		} else {
@@ -474,13 +955,17 @@ func main() {
 		}
 	}
 
+	// Catch termination signals early, so one arriving during the first check still shuts down gracefully
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
 	// Run once immediately
 	checkAndUpdateIP(config)
 
 	// Setup cron scheduler
-	c := cron.New()
-	_, err := c.AddFunc(config.CronSchedule, func() {
-		checkAndUpdateIP(config)
+	c := cron.New(cron.WithLocation(time.Local), cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)))
+	entryID, err := c.AddFunc(config.CronSchedule, func() {
+		checkAndUpdateIP(*activeConfig.Load())
 	})
 
 	if err != nil {
@@ -488,19 +973,70 @@ func main() {
 	}
 
 	c.Start()
+	scheduler.Store(c)
+
+	// Dump the internal state to the log on SIGUSR2 for live debugging
+	go handleDumpSignal(c)
+
+	// Show that the process is alive between checks
+	if config.HeartbeatInterval > 0 {
+		go logHeartbeat(c, config.HeartbeatInterval)
+	}
 
 	log.Printf("Cloudflare IP Updater running on schedule: %s", config.CronSchedule)
 
+	// Apply configuration changes from the remote backend without restarting
+	if backend != nil && backend.WatchInterval > 0 {
+		go watchConfigBackend(backend, func() error {
+			configMutex.Lock()
+			defer configMutex.Unlock()
+
+			newConfig, err := buildConfig()
+			if err != nil {
+				return err
+			}
+
+			// Keep the account resolved at startup
+			previous := activeConfig.Load()
+			if newConfig.AccountID == "" {
+				newConfig.AccountID = previous.AccountID
+			}
+			if newConfig.CronSchedule != previous.CronSchedule {
+				newEntryID, err := c.AddFunc(newConfig.CronSchedule, func() {
+					checkAndUpdateIP(*activeConfig.Load())
+				})
+				if err != nil {
+					return fmt.Errorf("invalid CRON %q: %w", newConfig.CronSchedule, err)
+				}
+				c.Remove(entryID)
+				entryID = newEntryID
+				log.Printf("Cloudflare IP Updater now running on schedule: %s", newConfig.CronSchedule)
+			}
+
+			activeConfig.Store(&newConfig)
+			log.Println("Configuration reloaded")
+			return nil
+		})
+	}
+
+	// Pick up a rotated token without restarting
+	if config.AuthTokenFile != "" {
+		go watchAuthTokenFile(config.AuthTokenFile)
+	}
+
 	// Wait for the termination signal
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt, os.Kill)
 	<-sig
 
-	c.Stop()
+	// Let a running check finish, so its update and notification are not cut off
+	config = *activeConfig.Load()
+	if !waitForRunningCheck(c, config.ShutdownTimeout) {
+		log.Printf("Check still running after %s, stopping anyway", config.ShutdownTimeout)
+	}
 
-	// Send notification on shutdown if configured
+	// Send notification on shutdown if configured, summarizing the session
+	log.Printf("Session summary:\n%s", sessionSummary(Configuration{DisplayLocation: config.DisplayLocation})) // logs stay in English
 	if config.NotificationURL != "" {
-		err := sendNotification(config, "⏹️ Cloudflare IP Updater stopped")
+		err := sendNotification(config, SeverityInfo, tr(config, "⏹️ Cloudflare IP Updater stopped")+"\n\n"+sessionSummary(config))
 		if err != nil {
 			return
 		}
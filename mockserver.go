@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mockCloudflare is an in-memory stand-in for the Cloudflare Access Groups API
+type mockCloudflare struct {
+	mu     sync.Mutex
+	groups map[string]map[string]interface{} // keyed by "<account>/<group>"
+}
+
+// runMockServer serves a fake, stateful Access Groups API for local development
+func runMockServer(args []string) {
+	flags := flag.NewFlagSet("mock-server", flag.ExitOnError)
+	addr := flags.String("addr", ":8787", "Address to listen on")
+	_ = flags.Parse(args)
+
+	mock := &mockCloudflare{groups: make(map[string]map[string]interface{})}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /client/v4/user/tokens/verify", mock.handleVerify)
+	mux.HandleFunc("GET /client/v4/accounts", mock.handleListAccounts)
+	mux.HandleFunc("GET /client/v4/accounts/{account}/access/groups", mock.handleListGroups)
+	mux.HandleFunc("GET /client/v4/accounts/{account}/access/groups/{group}", mock.handleGetGroup)
+	mux.HandleFunc("PUT /client/v4/accounts/{account}/access/groups/{group}", mock.handlePutGroup)
+
+	log.Printf("Mock Cloudflare API listening on %s", *addr)
+	log.Printf("Point the updater at it with CF_API_BASE_URL=http://localhost%s/client/v4", *addr)
+	if err := http.ListenAndServe(*addr, mock.requireToken(mux)); err != nil {
+		log.Fatalf("Mock server error: %v", err)
+	}
+}
+
+// requireToken rejects requests without a bearer token, like the real API does
+func (m *mockCloudflare) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Mock API: %s %s", r.Method, r.URL.Path)
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			writeMockResponse(w, http.StatusUnauthorized, nil, "Authentication error")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *mockCloudflare) handleVerify(w http.ResponseWriter, r *http.Request) {
+	writeMockResponse(w, http.StatusOK, map[string]interface{}{
+		"id":     "mock-token",
+		"status": "active",
+	}, "")
+}
+
+func (m *mockCloudflare) handleGetGroup(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group := m.group(r.PathValue("account"), r.PathValue("group"))
+	writeMockResponse(w, http.StatusOK, group, "")
+}
+
+// handleListAccounts returns the single account of the mock token
+func (m *mockCloudflare) handleListAccounts(w http.ResponseWriter, r *http.Request) {
+	writeMockListResponse(w, []interface{}{
+		map[string]interface{}{"id": "mock-account", "name": "Mock Account"},
+	}, map[string]interface{}{"page": 1, "per_page": 1, "count": 1, "total_count": 1, "total_pages": 1})
+}
+
+// handleListGroups returns the groups of the account created so far, paginated like the real API
+func (m *mockCloudflare) handleListGroups(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	page, perPage = max(page, 1), max(perPage, 1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var groups []interface{}
+	keys := slices.Sorted(maps.Keys(m.groups))
+	for _, key := range keys {
+		if strings.HasPrefix(key, r.PathValue("account")+"/") {
+			groups = append(groups, m.groups[key])
+		}
+	}
+
+	start, end := min((page-1)*perPage, len(groups)), min(page*perPage, len(groups))
+	writeMockListResponse(w, groups[start:end], map[string]interface{}{
+		"page":        page,
+		"per_page":    perPage,
+		"count":       end - start,
+		"total_count": len(groups),
+		"total_pages": (len(groups) + perPage - 1) / perPage,
+	})
+}
+
+func (m *mockCloudflare) handlePutGroup(w http.ResponseWriter, r *http.Request) {
+	var update map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeMockResponse(w, http.StatusBadRequest, nil, "Invalid JSON body: "+err.Error())
+		return
+	}
+
+	include, ok := update["include"].([]interface{})
+	if !ok {
+		writeMockResponse(w, http.StatusBadRequest, nil, "include is required")
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group := m.group(r.PathValue("account"), r.PathValue("group"))
+	group["include"] = include
+	for _, key := range []string{"name", "require", "exclude"} {
+		if value, ok := update[key]; ok {
+			group[key] = value
+		}
+	}
+	group["updated_at"] = time.Now().UTC().Format(time.RFC3339)
+
+	writeMockResponse(w, http.StatusOK, group, "")
+}
+
+// group returns the stored group, creating an empty one on first access
+func (m *mockCloudflare) group(account, id string) map[string]interface{} {
+	key := account + "/" + id
+	group, ok := m.groups[key]
+	if !ok {
+		now := time.Now().UTC().Format(time.RFC3339)
+		group = map[string]interface{}{
+			"id":         id,
+			"name":       "Mock Access Group",
+			"uid":        id,
+			"include":    []interface{}{},
+			"require":    []interface{}{},
+			"exclude":    []interface{}{},
+			"created_at": now,
+			"updated_at": now,
+		}
+		m.groups[key] = group
+	}
+	return group
+}
+
+// writeMockListResponse writes a Cloudflare-style response envelope for a page of a list
+func writeMockListResponse(w http.ResponseWriter, result []interface{}, resultInfo map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"result":      append([]interface{}{}, result...),
+		"result_info": resultInfo,
+		"success":     true,
+		"errors":      []interface{}{},
+		"messages":    []interface{}{},
+	})
+	if err != nil {
+		log.Printf("Failed to write mock response: %v", err)
+	}
+}
+
+// writeMockResponse writes a Cloudflare-style response envelope
+func writeMockResponse(w http.ResponseWriter, status int, result interface{}, errorMessage string) {
+	errs := []interface{}{}
+	if errorMessage != "" {
+		errs = append(errs, map[string]interface{}{"code": status, "message": errorMessage})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"result":   result,
+		"success":  errorMessage == "",
+		"errors":   errs,
+		"messages": []interface{}{},
+	})
+	if err != nil {
+		log.Printf("Failed to write mock response: %v", err)
+	}
+}
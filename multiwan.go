@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// primaryWANIP returns the IP of the first uplink that has one
+func primaryWANIP(ips []string) string {
+	for _, ip := range ips {
+		if ip != "" {
+			return ip
+		}
+	}
+	return ""
+}
+
+// interfaceWANIPs returns the public address of each local network interface, "" for those without one.
+// This is meant for updaters running on the router itself.
+func interfaceWANIPs(names []string) ([]string, error) {
+	ips := make([]string, len(names))
+	for i, name := range names {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("WAN interface %s: %w", name, err)
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("WAN interface %s: %w", name, err)
+		}
+
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if ok && iface.Flags&net.FlagUp != 0 && isPublicIP(ipNet.IP) {
+				ips[i] = ipNet.IP.String()
+				break
+			}
+		}
+		if ips[i] == "" {
+			log.Printf("No public address on WAN interface %s", name)
+		}
+	}
+
+	if primaryWANIP(ips) == "" {
+		return nil, fmt.Errorf("no public address on WAN interfaces %s", strings.Join(names, ", "))
+	}
+	return ips, nil
+}
+
+// detectWANIPs returns the public IP of each uplink, keeping the last known IP of uplinks that are
+// currently down so their entry stays in the group for when the site fails over to them
+func detectWANIPs(ctx context.Context, config Configuration) ([]string, error) {
+	var ips []string
+	var err error
+	if len(config.WANInterfaces) > 0 {
+		ips, err = interfaceWANIPs(config.WANInterfaces)
+	} else {
+		ips, err = fetchUniFiWANIPs(ctx, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	for i, ip := range ips {
+		if ip == "" && i < len(state.WANIPs) && state.WANIPs[i] != "" {
+			log.Printf("WAN%d has no address, keeping its last known IP %s", i+1, state.WANIPs[i])
+			ips[i] = state.WANIPs[i]
+		}
+	}
+	return ips, nil
+}
+
+// rememberWANIPs keeps the IP of each uplink, for when it is down on a later check
+func rememberWANIPs(config Configuration, ips []string) {
+	state, err := loadState(config.StateFile)
+	if err == nil && slices.Equal(state.WANIPs, ips) {
+		return
+	}
+	err = updateState(config.StateFile, func(state *State) error {
+		state.WANIPs = ips
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to remember the WAN IPs: %v", err)
+	}
+}
+
+// uniqueWANIPs lists the distinct IPs of the uplinks that have one, primary first
+func uniqueWANIPs(ips []string) []string {
+	var unique []string
+	for _, ip := range ips {
+		if ip != "" && !slices.Contains(unique, ip) {
+			unique = append(unique, ip)
+		}
+	}
+	return unique
+}
+
+// updateWANIPs publishes the IPs of all uplinks as the managed entries of every target Access Group
+func updateWANIPs(ctx context.Context, config Configuration, result *RunResult) {
+	var ips []string
+	var err error
+	detectionStart := time.Now()
+	if config.SimulateIP != "" {
+		log.Printf("Using simulated IP: %s", config.SimulateIP)
+		ips = []string{config.SimulateIP}
+	} else {
+		ips, err = detectWANIPs(ctx, config)
+	}
+	result.DetectionTime = time.Since(detectionStart)
+	recordComponent(ComponentIPDetection, err)
+	if err != nil {
+		log.Printf("Error getting WAN IPs: %v", err)
+		result.fail(ErrorCategoryIPDetection, err)
+		if config.NotificationURL != "" {
+			if err := sendNotification(config, SeverityError, tr(config, "❌ Error getting WAN IPs: %v", err)); err != nil {
+				log.Printf("Failed to send notification: %v", err)
+			}
+		}
+		return
+	}
+
+	if config.SimulateIP == "" {
+		rememberWANIPs(config, ips)
+	}
+
+	wanIPs := uniqueWANIPs(ips)
+	log.Printf("Current WAN IPs: %s", strings.Join(wanIPs, ", "))
+	result.NewIP = wanIPs[0]
+
+	*result = runTargets(config, *result, func(target Configuration, targetResult *RunResult) {
+		updateGroupWANIPs(ctx, target, wanIPs, targetResult)
+	})
+}
+
+// updateGroupWANIPs replaces the managed entries of the Access Group with the WAN IPs, if they changed
+func updateGroupWANIPs(ctx context.Context, config Configuration, wanIPs []string, result *RunResult) {
+	cfGroup, err := getCloudflareGroup(ctx, config)
+	if err != nil {
+		log.Printf("Error getting Cloudflare Access Group: %v", err)
+		result.fail(ErrorCategoryCloudflare, err)
+		if config.NotificationURL != "" {
+			if err := sendNotification(config, SeverityError, tr(config, "❌ Error getting Cloudflare Access Group: %v", err)); err != nil {
+				log.Printf("Failed to send notification: %v", err)
+			}
+		}
+		return
+	}
+
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		result.fail(ErrorCategoryState, err)
+		return
+	}
+
+	// Everything in the include list that isn't a static IP is managed by the updater
+	var current, previousIPs []string
+	for _, rule := range cfGroup.Result.Include {
+		if rule.IP.IP == "" {
+			continue
+		}
+		current = append(current, rule.IP.IP)
+		if !slices.Contains(state.StaticIPs, rule.IP.IP) {
+			previousIPs = append(previousIPs, cidrToIP(rule.IP.IP))
+		}
+	}
+	if len(previousIPs) > 0 {
+		result.PreviousIP = previousIPs[0]
+	}
+
+	var desired []string
+	for _, ip := range wanIPs {
+		desired = append(desired, ipToCIDR(ip))
+	}
+	desired = append(desired, state.StaticIPs...)
+
+	if slices.Equal(current, desired) {
+		log.Println("WAN IPs are already up to date, no action needed")
+		result.Action = ActionNoChange
+		return
+	}
+
+	if len(previousIPs) == 0 {
+		log.Printf("No WAN IPs found in Cloudflare Access Group, setting %s", strings.Join(wanIPs, ", "))
+	} else {
+		log.Printf("Updating Cloudflare Access Group WAN IPs from %s to %s", strings.Join(previousIPs, ", "), strings.Join(wanIPs, ", "))
+	}
+	updated, err := updateCloudflareGroup(ctx, config, wanIPs...)
+	if err != nil {
+		log.Printf("Error updating Cloudflare Access Group: %v", err)
+		result.fail(ErrorCategoryCloudflare, err)
+		if config.NotificationURL != "" {
+			if err := sendNotification(config, SeverityError, tr(config, "❌ Failed to update WAN IPs to %s: %v", strings.Join(wanIPs, ", "), err)); err != nil {
+				log.Printf("Failed to send notification: %v", err)
+			}
+		}
+		return
+	}
+
+	log.Printf("Successfully updated Cloudflare Access Group with WAN IPs: %s", strings.Join(wanIPs, ", "))
+	result.Action = ActionUpdated
+	diff := logGroupDiff(config, cfGroup.Raw, updated)
+
+	if config.NotificationURL != "" {
+		message := tr(config, "🔄 WAN IPs Updated: %s ➡️ %s", strings.Join(previousIPs, ", "), strings.Join(wanIPs, ", "))
+		if len(previousIPs) == 0 {
+			message = tr(config, "✅ Initial WAN IPs set in Cloudflare Access Group: %s", strings.Join(wanIPs, ", "))
+		}
+		if err := sendNotification(config, SeverityInfo, withGroupDiff(config, message, diff)); err != nil {
+			log.Printf("Failed to send notification: %v", err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// notificationBatch collects the notifications of the Access Groups of a run, so they are sent as one
+// message instead of flooding the channel with one per group
+type notificationBatch struct {
+	mutex    sync.Mutex
+	messages map[string][]string // by RULEID
+	severity notificationSeverity
+}
+
+// add keeps a notification of an Access Group for the combined message, which gets the highest severity
+func (b *notificationBatch) add(ruleID string, severity notificationSeverity, message string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.messages == nil {
+		b.messages = map[string][]string{}
+	}
+	b.messages[ruleID] = append(b.messages[ruleID], message)
+	b.severity = max(b.severity, severity)
+}
+
+// send delivers the collected notifications, a single one unchanged and several as one message
+// listing every Access Group with its outcome
+func (b *notificationBatch) send(config Configuration, targets []TargetResult) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var count int
+	var single, singleRuleID string
+	for ruleID, messages := range b.messages {
+		count += len(messages)
+		single, singleRuleID = messages[0], ruleID
+	}
+	if count == 0 {
+		return
+	}
+
+	if count == 1 {
+		target := config
+		target.NotificationIdentifier = strings.TrimSpace(fmt.Sprintf("%s [%s]", config.NotificationIdentifier, singleRuleID))
+		if err := sendNotification(target, b.severity, single); err != nil {
+			log.Printf("Failed to send notification: %v", err)
+		}
+		return
+	}
+
+	var lines []string
+	for _, target := range targets {
+		messages := b.messages[target.RuleID]
+		if len(messages) == 0 {
+			lines = append(lines, fmt.Sprintf("• %s: %s", target.RuleID, target.Action))
+			continue
+		}
+		for _, message := range messages {
+			lines = append(lines, fmt.Sprintf("• %s: %s", target.RuleID, message))
+		}
+	}
+
+	icon := "🔄"
+	switch b.severity {
+	case SeverityWarning:
+		icon = "⚠️"
+	case SeverityError:
+		icon = "❌"
+	}
+	header := icon + " " + tr(config, "%d of %d Access Groups changed or failed:", len(b.messages), len(targets))
+	if err := sendNotification(config, b.severity, header+"\n"+strings.Join(lines, "\n")); err != nil {
+		log.Printf("Failed to send notification: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ipAddressPattern matches IPv4 and IPv6 addresses (with an optional prefix length) in notification messages
+var ipAddressPattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}(?:/\d{1,2})?\b|\b[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7}(?:/\d{1,3})?`)
+
+// parseNotificationMaxLength parses NOTIFICATION_MAX_LENGTH: either a single limit applying to every
+// service, or a comma-separated list of <scheme>=<limit> (e.g. "ntfy=250,pushover=1024,*=2000")
+func parseNotificationMaxLength(value string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, entry := range splitList(value) {
+		scheme, limit, found := strings.Cut(entry, "=")
+		if !found {
+			scheme, limit = "*", entry
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(limit))
+		if err != nil || n < 20 {
+			return nil, fmt.Errorf("invalid limit %q, expected a number of characters of at least 20", entry)
+		}
+		limits[strings.ToLower(strings.TrimSpace(scheme))] = n
+	}
+	return limits, nil
+}
+
+// notificationMaxLength returns the message length limit for the notification service, 0 for none
+func notificationMaxLength(config Configuration) int {
+	if len(config.NotificationMaxLength) == 0 {
+		return 0
+	}
+
+	if parsed, err := url.Parse(config.NotificationURL); err == nil {
+		if limit, ok := config.NotificationMaxLength[strings.ToLower(parsed.Scheme)]; ok {
+			return limit
+		}
+	}
+	return config.NotificationMaxLength["*"]
+}
+
+// truncateNotification shortens a message to max characters. The beginning, which carries the
+// severity and the subject, is kept, and IP addresses from the dropped part are appended as far as
+// they fit, so that as many addresses as possible survive.
+func truncateNotification(message string, max int) string {
+	runes := []rune(message)
+	if max <= 0 || len(runes) <= max {
+		return message
+	}
+
+	// Locate the IP addresses, in characters
+	type span struct {
+		start, end int
+		ip         string
+	}
+	var ips []span
+	for _, match := range ipAddressPattern.FindAllStringIndex(message, -1) {
+		ip := message[match[0]:match[1]]
+		if address, _, _ := strings.Cut(ip, "/"); net.ParseIP(address) != nil {
+			start := utf8.RuneCountInString(message[:match[0]])
+			ips = append(ips, span{start, start + utf8.RuneCountInString(ip), ip})
+		}
+	}
+
+	// The severity emoji or first word always stays
+	minCut := len([]rune(strings.SplitN(message, " ", 2)[0]))
+
+	const ellipsis = "…"
+	best, bestPreserved := "", -1
+	for cut := max - 1; cut >= minCut; cut-- {
+		// Never split an address
+		for _, ip := range ips {
+			if ip.start < cut && cut < ip.end {
+				cut = ip.start
+			}
+		}
+
+		kept := strings.TrimRight(string(runes[:cut]), " ")
+		preserved := 0
+		var lost []string
+		for _, ip := range ips {
+			if ip.end <= cut {
+				preserved++
+			} else if !strings.Contains(kept, ip.ip) && !slices.Contains(lost, ip.ip) {
+				lost = append(lost, ip.ip)
+			}
+		}
+
+		// Append the dropped addresses in order while they fit
+		suffix := ellipsis
+		for i := len(lost); i > 0; i-- {
+			candidate := fmt.Sprintf("%s (%s)", ellipsis, strings.Join(lost[:i], ", "))
+			if len([]rune(kept))+len([]rune(candidate)) <= max {
+				suffix = candidate
+				preserved += i
+				break
+			}
+		}
+		if len([]rune(kept))+len([]rune(suffix)) > max {
+			continue
+		}
+
+		if preserved > bestPreserved {
+			best, bestPreserved = kept+suffix, preserved
+		}
+		if len(lost) == 0 || suffix != ellipsis && preserved == len(ips) {
+			break
+		}
+	}
+
+	if best == "" {
+		return string(runes[:max-1]) + ellipsis
+	}
+	return best
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/containrrr/shoutrrr"
+	"github.com/containrrr/shoutrrr/pkg/types"
+)
+
+// notificationSeverity tells informational notifications from warnings and failures, which get
+// NOTIFICATION_WARNING_PARAMS and NOTIFICATION_ERROR_PARAMS
+type notificationSeverity int
+
+// Notification severities, from the least to the most severe
+const (
+	SeverityInfo notificationSeverity = iota
+	SeverityWarning
+	SeverityError
+)
+
+// parseNotificationParams parses a comma-separated list of shoutrrr parameters as <key>=<value>,
+// e.g. "title=IP Updater,priority=1"
+func parseNotificationParams(value string) (map[string]string, error) {
+	params := make(map[string]string)
+	for _, entry := range splitList(value) {
+		key, param, found := strings.Cut(entry, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid parameter %q, expected <key>=<value>", entry)
+		}
+		params[key] = strings.TrimSpace(param)
+	}
+	return params, nil
+}
+
+// notificationParams returns the shoutrrr parameters of a notification, with NOTIFICATION_WARNING_PARAMS
+// or NOTIFICATION_ERROR_PARAMS taking precedence for warnings and failures, nil when none are configured
+func notificationParams(config Configuration, severity notificationSeverity) types.Params {
+	params := maps.Clone(config.NotifyParams)
+	var overrides map[string]string
+	switch severity {
+	case SeverityWarning:
+		overrides = config.NotifyWarningParams
+	case SeverityError:
+		overrides = config.NotifyErrorParams
+	}
+	if len(overrides) > 0 {
+		if params == nil {
+			params = make(types.Params)
+		}
+		maps.Copy(params, overrides)
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	return params
+}
+
+// sendShoutrrr delivers a message to NOTIFICATION_URL, passing the parameters to the service if any
+func sendShoutrrr(url string, message string, params types.Params) error {
+	if params == nil {
+		return shoutrrr.Send(url, message)
+	}
+
+	sender, err := shoutrrr.CreateSender(url)
+	if err != nil {
+		return err
+	}
+	return errors.Join(sender.Send(message, &params)...)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// testNotification is a message of notify-test
+type testNotification struct {
+	severity notificationSeverity
+	message  string
+}
+
+// testNotifications are the messages of notify-test, by severity
+var testNotifications = map[string]testNotification{
+	"info":    {SeverityInfo, "🧪 Test notification from the Cloudflare IP Updater"},
+	"warning": {SeverityWarning, "⚠️ Test warning from the Cloudflare IP Updater"},
+	"error":   {SeverityError, "❌ Test error from the Cloudflare IP Updater"},
+}
+
+// runNotifyTest implements the notify-test subcommand, sending a test message through the configured
+// notification settings, so changes to them can be checked without restarting the updater
+func runNotifyTest(args []string) {
+	flags := flag.NewFlagSet("notify-test", flag.ExitOnError)
+	profile := flags.String("profile", "", "Named configuration profile to use")
+	severity := flags.String("severity", "info", "Severity of the test message: info, warning or error")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cloudflare-access-group-ip-updater notify-test [--profile name] [--severity info|warning|error]")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	test, ok := testNotifications[*severity]
+	if !ok {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	initConfigSources(*profile)
+	// Sending a message needs no Cloudflare settings
+	config, err := loadCommandConfig("ACCOUNTID", "RULEID")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.NotificationURL == "" {
+		log.Fatal("NOTIFICATION_URL is not set")
+	}
+
+	if err := sendNotification(config, test.severity, tr(config, test.message)); err != nil {
+		log.Fatalf("Test notification failed: %v", err)
+	}
+	log.Printf("Test %s notification sent", *severity)
+}
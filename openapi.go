@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// openAPIPaths documents the endpoints served by the HTTP server; add an entry whenever an endpoint is added
+var openAPIPaths = map[string]interface{}{
+	"/health": map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "getHealth",
+			"summary":     "Liveness check",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "The process is running. The first line is the overall status (OK, DEGRADED or FAILING), followed by the status of every component.",
+					"content": map[string]interface{}{
+						"text/plain": map[string]interface{}{
+							"schema": map[string]interface{}{"type": "string", "example": "OK\nip_detection: ok\ncloudflare_api: ok\nnotifications: disabled\nscheduler: ok\n"},
+						},
+					},
+				},
+			},
+		},
+	},
+	"/ready": map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "getReady",
+			"summary":     "Readiness check with details",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "The updater is ready",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{"$ref": "#/components/schemas/Ready"},
+						},
+					},
+				},
+			},
+		},
+	},
+	"/api/providers": map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "getProviders",
+			"summary":     "Statistics and circuit breaker state of every IP provider",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "The configured providers in the order they are tried, then providers used earlier",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{"$ref": "#/components/schemas/Providers"},
+						},
+					},
+				},
+			},
+		},
+	},
+	"/api/openapi.json": map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "getOpenAPI",
+			"summary":     "This OpenAPI document",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OpenAPI 3.1 document",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{"type": "object"},
+						},
+					},
+				},
+			},
+		},
+	},
+	"/api/set-ip": map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "setIP",
+			"summary":     "Write a specific IP to the Access Group, bypassing detection",
+			"description": "Only available when API_TOKEN is configured. An optional hold duration pauses automatic updates so the next scheduled check doesn't revert the change.",
+			"security":    []map[string][]string{{"bearerAuth": {}}},
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"$ref": "#/components/schemas/SetIPRequest"},
+					},
+				},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "The Access Group was updated",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{"$ref": "#/components/schemas/SetIPResponse"},
+						},
+					},
+				},
+				"400": map[string]interface{}{"$ref": "#/components/responses/Error"},
+				"401": map[string]interface{}{"$ref": "#/components/responses/Error"},
+				"409": map[string]interface{}{"$ref": "#/components/responses/Error"},
+				"429": map[string]interface{}{"$ref": "#/components/responses/Error"},
+				"502": map[string]interface{}{"$ref": "#/components/responses/Error"},
+			},
+		},
+	},
+	"/api/token": map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "rotateToken",
+			"summary":     "Replace the Cloudflare API token without a restart",
+			"description": "Only available when API_TOKEN is configured. The new token is verified and must be able to read every target Access Group before it replaces the current one. With AUTH_TOKEN_FILE, the file is updated as well.",
+			"security":    []map[string][]string{{"bearerAuth": {}}},
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"$ref": "#/components/schemas/RotateTokenRequest"},
+					},
+				},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{"description": "The new token is in use"},
+				"400": map[string]interface{}{"$ref": "#/components/responses/Error"},
+				"401": map[string]interface{}{"$ref": "#/components/responses/Error"},
+				"502": map[string]interface{}{"$ref": "#/components/responses/Error"},
+			},
+		},
+	},
+	"/webhooks/cloudflare": map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "cloudflareWebhook",
+			"summary":     "Receive a Cloudflare notification and run a drift check",
+			"description": "Only available when WEBHOOK_SECRET is configured, which Cloudflare sends in the cf-webhook-auth header. Webhooks arriving within a few seconds are coalesced into one check.",
+			"security":    []map[string][]string{{"webhookSecret": {}}},
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"$ref": "#/components/schemas/CloudflareWebhook"},
+					},
+				},
+			},
+			"responses": map[string]interface{}{
+				"202": map[string]interface{}{"description": "A drift check was scheduled"},
+				"400": map[string]interface{}{"$ref": "#/components/responses/Error"},
+				"401": map[string]interface{}{"$ref": "#/components/responses/Error"},
+			},
+		},
+	},
+}
+
+// openAPISchemas holds the reusable response schemas referenced from openAPIPaths
+var openAPISchemas = map[string]interface{}{
+	"Ready": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"status", "timestamp", "uptime", "recent_errors", "build", "config_fingerprint", "features"},
+		"properties": map[string]interface{}{
+			"status":    map[string]interface{}{"type": "string", "enum": []string{"OK", "DEGRADED"}, "description": "DEGRADED when the last run failed or a component isn't ok"},
+			"timestamp": map[string]interface{}{"type": "string", "format": "date-time"},
+			"uptime":    map[string]interface{}{"type": "string", "example": "3h25m10s"},
+			"last_run": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"timestamp":    map[string]interface{}{"type": "string", "format": "date-time"},
+					"action":       map[string]interface{}{"type": "string", "enum": []string{ActionNoChange, ActionUpdated, ActionPending, ActionFlapping, ActionSkipped, ActionError}},
+					"provider":     map[string]interface{}{"type": "string", "description": "IP provider that answered", "example": "api.ipify.org"},
+					"detection_ms": map[string]interface{}{"type": "integer", "description": "Time spent detecting the IP"},
+					"update_ms":    map[string]interface{}{"type": "integer", "description": "Time spent updating Cloudflare, 0 without a change"},
+				},
+			},
+			"last_successful_update": map[string]interface{}{"type": "string", "format": "date-time"},
+			"counters":               map[string]interface{}{"$ref": "#/components/schemas/Counters"},
+			"components": map[string]interface{}{
+				"type":                 "object",
+				"description":          "Status of each component: ip_detection, cloudflare_api, notifications and scheduler",
+				"additionalProperties": map[string]interface{}{"$ref": "#/components/schemas/ComponentHealth"},
+			},
+			"build": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"version":    map[string]interface{}{"type": "string", "example": "v1.4.0"},
+					"commit":     map[string]interface{}{"type": "string"},
+					"go_version": map[string]interface{}{"type": "string", "example": "go1.24.2"},
+				},
+			},
+			"config_fingerprint": map[string]interface{}{"type": "string", "description": "Hash of the effective configuration, secrets only count as set or unset", "example": "3f2a9c1b7d4e"},
+			"features": map[string]interface{}{
+				"type":        "array",
+				"description": "Optional features enabled by the configuration",
+				"items":       map[string]interface{}{"type": "string"},
+			},
+			"recent_errors": map[string]interface{}{
+				"type":        "array",
+				"description": "Most recent errors, newest first",
+				"items":       map[string]interface{}{"$ref": "#/components/schemas/RecordedError"},
+			},
+		},
+	},
+	"ComponentHealth": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"status":     map[string]interface{}{"type": "string", "enum": []string{ComponentOK, ComponentDegraded, ComponentFailing, ComponentUnknown, ComponentDisabled}},
+			"since":      map[string]interface{}{"type": "string", "format": "date-time", "description": "When the component entered the status"},
+			"last_error": map[string]interface{}{"type": "string"},
+		},
+	},
+	"Counters": map[string]interface{}{
+		"type":        "object",
+		"description": "Cumulative statistics of the check runs, kept in the state file across restarts",
+		"properties": map[string]interface{}{
+			"since":       map[string]interface{}{"type": "string", "format": "date-time", "description": "When counting started"},
+			"checks":      map[string]interface{}{"type": "integer"},
+			"updates":     map[string]interface{}{"type": "integer"},
+			"failures":    map[string]interface{}{"type": "integer"},
+			"last_update": map[string]interface{}{"type": "string", "format": "date-time"},
+			"this_month":  map[string]interface{}{"$ref": "#/components/schemas/MonthlyCounters"},
+			"monthly": map[string]interface{}{
+				"type":                 "object",
+				"description":          "The last 12 months, keyed by YYYY-MM",
+				"additionalProperties": map[string]interface{}{"$ref": "#/components/schemas/MonthlyCounters"},
+			},
+		},
+	},
+	"MonthlyCounters": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"checks":   map[string]interface{}{"type": "integer"},
+			"updates":  map[string]interface{}{"type": "integer"},
+			"failures": map[string]interface{}{"type": "integer"},
+		},
+	},
+	"RecordedError": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"timestamp": map[string]interface{}{"type": "string", "format": "date-time"},
+			"category": map[string]interface{}{
+				"type": "string",
+				"enum": []string{ErrorCategoryPreflight, ErrorCategoryIPDetection, ErrorCategoryCloudflare, ErrorCategoryNotification, ErrorCategoryState},
+			},
+			"message": map[string]interface{}{"type": "string"},
+		},
+	},
+	"Providers": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"window", "providers"},
+		"properties": map[string]interface{}{
+			"window": map[string]interface{}{"type": "integer", "description": "Number of recent lookups the success rate and latency are computed over"},
+			"providers": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"$ref": "#/components/schemas/ProviderStats"},
+			},
+		},
+	},
+	"ProviderStats": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"provider":             map[string]interface{}{"type": "string", "example": "https://api.ipify.org?format=json"},
+			"name":                 map[string]interface{}{"type": "string", "example": "api.ipify.org"},
+			"configured":           map[string]interface{}{"type": "boolean", "description": "false for providers used before a configuration reload"},
+			"successes":            map[string]interface{}{"type": "integer"},
+			"failures":             map[string]interface{}{"type": "integer"},
+			"recent_lookups":       map[string]interface{}{"type": "integer"},
+			"success_rate":         map[string]interface{}{"type": []string{"number", "null"}, "description": "Share of the recent lookups that succeeded, null without lookups", "example": 0.95},
+			"average_latency_ms":   map[string]interface{}{"type": "integer", "description": "Mean duration of the recent lookups"},
+			"last_success":         map[string]interface{}{"type": "string", "format": "date-time"},
+			"last_failure":         map[string]interface{}{"type": "string", "format": "date-time"},
+			"last_error":           map[string]interface{}{"type": "string"},
+			"consecutive_failures": map[string]interface{}{"type": "integer"},
+			"circuit_breaker":      map[string]interface{}{"type": "string", "enum": []string{BreakerClosed, BreakerOpen, BreakerHalfOpen}},
+			"open_until":           map[string]interface{}{"type": "string", "format": "date-time", "description": "When an open circuit breaker lets a trial lookup through"},
+		},
+	},
+	"SetIPRequest": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"ip"},
+		"properties": map[string]interface{}{
+			"ip":   map[string]interface{}{"type": "string", "example": "203.0.113.10"},
+			"hold": map[string]interface{}{"type": "string", "description": "Pause automatic updates for this duration", "example": "12h"},
+		},
+	},
+	"SetIPResponse": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"ip":         map[string]interface{}{"type": "string"},
+			"dry_run":    map[string]interface{}{"type": "boolean"},
+			"hold_until": map[string]interface{}{"type": "string", "format": "date-time"},
+		},
+	},
+	"RotateTokenRequest": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"token"},
+		"properties": map[string]interface{}{
+			"token": map[string]interface{}{"type": "string", "description": "The new Cloudflare API token"},
+		},
+	},
+	"CloudflareWebhook": map[string]interface{}{
+		"type":        "object",
+		"description": "Cloudflare notification payload, other fields are ignored",
+		"properties": map[string]interface{}{
+			"name":       map[string]interface{}{"type": "string"},
+			"text":       map[string]interface{}{"type": "string"},
+			"alert_type": map[string]interface{}{"type": "string"},
+		},
+	},
+	"Error": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error": map[string]interface{}{"type": "string"},
+		},
+	},
+}
+
+// openAPISpec builds the OpenAPI document for the HTTP server
+func openAPISpec() map[string]interface{} {
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       "Cloudflare Access Group IP Updater API",
+			"description": "Health, status and control endpoints of the Cloudflare Access Group IP Updater",
+			"version":     "1.0.0",
+			"license":     map[string]interface{}{"name": "MIT", "identifier": "MIT"},
+		},
+		"paths": openAPIPaths,
+		"components": map[string]interface{}{
+			"schemas": openAPISchemas,
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"},
+						},
+					},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth":    map[string]interface{}{"type": "http", "scheme": "bearer", "description": "The configured API_TOKEN"},
+				"webhookSecret": map[string]interface{}{"type": "apiKey", "in": "header", "name": "cf-webhook-auth", "description": "The configured WEBHOOK_SECRET"},
+			},
+		},
+	}
+}
+
+// handleOpenAPI serves the OpenAPI document
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	jsonData, err := json.MarshalIndent(openAPISpec(), "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(jsonData)
+	if err != nil {
+		return
+	}
+}
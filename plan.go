@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+// groupPlan describes the change a check run would make to the Access Group
+type groupPlan struct {
+	Group   string
+	Current []string // the detected public IP, or the IP of each uplink with MULTI_WAN
+	Desired []string
+	Changes []string
+}
+
+// planGroupUpdate computes the change checkAndUpdateIP would make for the detected IP
+func planGroupUpdate(group groupDetails, currentIP string, staticIPs []string) groupPlan {
+	plan := groupPlan{
+		Group:   group.Result.Name,
+		Current: []string{currentIP},
+		Desired: append([]string{ipToCIDR(currentIP)}, staticIPs...),
+	}
+
+	include := group.Result.Include
+	var previousCIDR string
+	if len(include) > 0 {
+		if ip, ok := include[0]["ip"].(map[string]interface{}); ok {
+			previousCIDR, _ = ip["ip"].(string)
+		}
+	}
+
+	// Same decision as checkAndUpdateIP: nothing is written while the first entry matches
+	if previousCIDR != "" && cidrToIP(previousCIDR) == currentIP {
+		return plan
+	}
+
+	dynamicReplaced := false
+	if previousCIDR != "" && !slices.Contains(staticIPs, previousCIDR) {
+		plan.Changes = append(plan.Changes, fmt.Sprintf("~ ip %s → %s  (dynamic IP)", previousCIDR, plan.Desired[0]))
+		dynamicReplaced = true
+	} else {
+		plan.Changes = append(plan.Changes, fmt.Sprintf("+ ip %s  (dynamic IP)", plan.Desired[0]))
+	}
+
+	current := make([]string, 0, len(include))
+	for i, rule := range include {
+		if i == 0 && dynamicReplaced {
+			continue
+		}
+		if ip, ok := rule["ip"].(map[string]interface{}); ok {
+			cidr, _ := ip["ip"].(string)
+			current = append(current, cidr)
+			if !slices.Contains(plan.Desired, cidr) {
+				plan.Changes = append(plan.Changes, fmt.Sprintf("- ip %s", cidr))
+			}
+			continue
+		}
+		plan.Changes = append(plan.Changes, fmt.Sprintf("- %s  (not managed, dropped by the update)", formatRule(rule)))
+	}
+
+	for _, cidr := range staticIPs {
+		if !slices.Contains(current, cidr) {
+			plan.Changes = append(plan.Changes, fmt.Sprintf("+ ip %s  (static)", cidr))
+		}
+	}
+
+	return plan
+}
+
+// planWANUpdate computes the change updateGroupWANIPs would make for the IPs of the uplinks, where every
+// IP entry that isn't static is managed
+func planWANUpdate(group groupDetails, wanIPs []string, staticIPs []string) groupPlan {
+	plan := groupPlan{Group: group.Result.Name, Current: wanIPs}
+	for _, ip := range wanIPs {
+		plan.Desired = append(plan.Desired, ipToCIDR(ip))
+	}
+	plan.Desired = append(plan.Desired, staticIPs...)
+
+	var current []string
+	for _, rule := range group.Result.Include {
+		if ip, ok := rule["ip"].(map[string]interface{}); ok {
+			cidr, _ := ip["ip"].(string)
+			current = append(current, cidr)
+		}
+	}
+
+	// Same decision as updateGroupWANIPs: nothing is written while the IP entries match
+	if slices.Equal(current, plan.Desired) {
+		return plan
+	}
+
+	for _, rule := range group.Result.Include {
+		ip, ok := rule["ip"].(map[string]interface{})
+		if !ok {
+			plan.Changes = append(plan.Changes, fmt.Sprintf("- %s  (not managed, dropped by the update)", formatRule(rule)))
+			continue
+		}
+		if cidr, _ := ip["ip"].(string); !slices.Contains(plan.Desired, cidr) {
+			plan.Changes = append(plan.Changes, fmt.Sprintf("- ip %s  (WAN IP)", cidr))
+		}
+	}
+	for i, cidr := range plan.Desired {
+		switch {
+		case slices.Contains(current, cidr):
+		case i < len(wanIPs):
+			plan.Changes = append(plan.Changes, fmt.Sprintf("+ ip %s  (WAN IP)", cidr))
+		default:
+			plan.Changes = append(plan.Changes, fmt.Sprintf("+ ip %s  (static)", cidr))
+		}
+	}
+	if len(plan.Changes) == 0 {
+		plan.Changes = append(plan.Changes, "~ include: reordered, primary uplink first")
+	}
+	return plan
+}
+
+// planGroupSpec computes the changes reconcileGroupSpec would make to match the spec
+func planGroupSpec(group groupDetails, spec groupSpec, currentIP string) groupPlan {
+	plan := groupPlan{Group: group.Result.Name, Current: []string{currentIP}}
+	for _, rule := range spec.Include {
+		plan.Desired = append(plan.Desired, formatRule(rule))
+	}
+
+	if spec.Name != "" && spec.Name != group.Result.Name {
+		plan.Changes = append(plan.Changes, fmt.Sprintf("~ name %s → %s", group.Result.Name, spec.Name))
+	}
+	for _, list := range []struct {
+		name        string
+		desired, is []map[string]interface{}
+	}{
+		{"include", spec.Include, group.Result.Include},
+		{"require", spec.Require, group.Result.Require},
+		{"exclude", spec.Exclude, group.Result.Exclude},
+	} {
+		desired, current := formatRules(list.desired), formatRules(list.is)
+		changes := len(plan.Changes)
+		for _, rule := range current {
+			if !slices.Contains(desired, rule) {
+				plan.Changes = append(plan.Changes, fmt.Sprintf("- %s: %s", list.name, rule))
+			}
+		}
+		for _, rule := range desired {
+			if !slices.Contains(current, rule) {
+				plan.Changes = append(plan.Changes, fmt.Sprintf("+ %s: %s", list.name, rule))
+			}
+		}
+		if len(plan.Changes) == changes && !sameRules(list.desired, list.is) {
+			plan.Changes = append(plan.Changes, fmt.Sprintf("~ %s: reordered", list.name))
+		}
+	}
+	return plan
+}
+
+// formatRules renders each rule of a list with formatRule
+func formatRules(rules []map[string]interface{}) []string {
+	formatted := make([]string, len(rules))
+	for i, rule := range rules {
+		formatted[i] = formatRule(rule)
+	}
+	return formatted
+}
+
+// print writes the plan in a human-readable form
+func (p groupPlan) print(w io.Writer) {
+	fmt.Fprintf(w, "Access Group: %s\n", p.Group)
+	if len(p.Current) > 1 {
+		fmt.Fprintf(w, "Detected IPs: %s\n\n", strings.Join(p.Current, ", "))
+	} else {
+		fmt.Fprintf(w, "Detected IP:  %s\n\n", strings.Join(p.Current, ", "))
+	}
+
+	if len(p.Changes) == 0 {
+		fmt.Fprintln(w, "No changes. The Access Group is up to date.")
+		return
+	}
+
+	fmt.Fprintln(w, "The following changes would be made:")
+	for _, change := range p.Changes {
+		fmt.Fprintf(w, "  %s\n", change)
+	}
+	fmt.Fprintf(w, "\nResulting include list: %s\n", strings.Join(p.Desired, ", "))
+}
+
+// runPlan implements the plan subcommand: detect the IP and show the change without applying it
+func runPlan(args []string) {
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	profile := flags.String("profile", "", "Named configuration profile to use")
+	ruleID := flags.String("group", "", "Access Group rule ID when RULEID lists several (default: the first)")
+	simulateIP := flags.String("simulate-ip", "", "Plan for this IP instead of detecting it")
+	_ = flags.Parse(args)
+
+	initConfigSources(*profile)
+	config, err := loadCommandConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *ruleID != "" {
+		config.RuleID = *ruleID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.RunTimeout)
+	defer cancel()
+
+	// Every uplink of a multi-WAN site is published
+	var wanIPs []string
+	if config.MultiWAN {
+		if *simulateIP != "" {
+			wanIPs = []string{*simulateIP}
+		} else {
+			ips, err := detectWANIPs(ctx, config)
+			if err != nil {
+				log.Fatalf("Error getting WAN IPs: %v", err)
+			}
+			wanIPs = uniqueWANIPs(ips)
+		}
+	}
+
+	currentIP := *simulateIP
+	if currentIP == "" && !config.MultiWAN {
+		currentIP, _, err = getCurrentIP(ctx, config)
+		if err != nil {
+			log.Fatalf("Error getting current IP: %v", err)
+		}
+	}
+	currentIP = strings.TrimSpace(currentIP)
+	if config.IPv6PrefixLength > 0 {
+		currentIP, err = ipv6Prefix(currentIP, config.IPv6PrefixLength)
+		if err != nil {
+			log.Fatalf("Error getting the delegated IPv6 prefix: %v", err)
+		}
+	}
+
+	body, err := fetchCloudflareGroup(ctx, config)
+	if err != nil {
+		log.Fatalf("Error getting Cloudflare Access Group: %v", err)
+	}
+	var group groupDetails
+	if err := json.Unmarshal(body, &group); err != nil {
+		log.Fatal(err)
+	}
+
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var plan groupPlan
+	if config.MultiWAN {
+		plan = planWANUpdate(group, wanIPs, state.StaticIPs)
+	} else if config.GroupSpecFile != "" {
+		template, err := loadGroupSpec(config.GroupSpecFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		spec, err := renderGroupSpec(template, currentIP)
+		if err != nil {
+			log.Fatal(err)
+		}
+		plan = planGroupSpec(group, withStaticIPs(spec, state.StaticIPs), currentIP)
+	} else {
+		plan = planGroupUpdate(group, currentIP, state.StaticIPs)
+	}
+	plan.print(os.Stdout)
+
+	// Same exit codes as --once mode
+	if len(plan.Changes) > 0 {
+		os.Exit(ExitUpdated)
+	}
+}
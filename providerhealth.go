@@ -0,0 +1,231 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// providerWindowSize is the number of recent lookups the success rate and latency are computed over
+const providerWindowSize = 20
+
+// Circuit breaker states of a provider
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// providerHealth tracks the outcomes of lookups against a single IP provider
+type providerHealth struct {
+	Successes   int       `json:"successes"`
+	Failures    int       `json:"failures"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	LastFailure time.Time `json:"last_failure,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+
+	// ConsecutiveFailures opens the circuit breaker once it reaches PROVIDER_BREAKER_THRESHOLD
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	OpenUntil           time.Time `json:"open_until,omitzero"`
+
+	// recent holds the last providerWindowSize lookups, oldest first
+	recent []providerLookup
+}
+
+// providerLookup is the outcome of a single lookup
+type providerLookup struct {
+	ok      bool
+	latency time.Duration
+}
+
+var (
+	providerHealthMutex sync.Mutex
+	providerHealthStats = make(map[string]*providerHealth)
+)
+
+// recordProviderResult updates the health of a provider after a lookup, opening its circuit breaker
+// after PROVIDER_BREAKER_THRESHOLD consecutive failures
+func recordProviderResult(config Configuration, provider string, latency time.Duration, err error) {
+	providerHealthMutex.Lock()
+	defer providerHealthMutex.Unlock()
+
+	health, ok := providerHealthStats[provider]
+	if !ok {
+		health = &providerHealth{}
+		providerHealthStats[provider] = health
+	}
+
+	health.recent = append(health.recent, providerLookup{ok: err == nil, latency: latency})
+	if len(health.recent) > providerWindowSize {
+		health.recent = health.recent[len(health.recent)-providerWindowSize:]
+	}
+
+	if err != nil {
+		health.Failures++
+		health.ConsecutiveFailures++
+		health.LastFailure = time.Now()
+		health.LastError = err.Error()
+		// A failed trial while half open opens the breaker again for a full cooldown
+		if config.BreakerThreshold > 0 && health.ConsecutiveFailures >= config.BreakerThreshold {
+			health.OpenUntil = time.Now().Add(config.BreakerCooldown)
+		}
+	} else {
+		health.Successes++
+		health.ConsecutiveFailures = 0
+		health.OpenUntil = time.Time{}
+		health.LastSuccess = time.Now()
+	}
+}
+
+// breakerState returns the circuit breaker state of the provider
+func (h providerHealth) breakerState() string {
+	switch {
+	case h.OpenUntil.IsZero():
+		return BreakerClosed
+	case time.Now().Before(h.OpenUntil):
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+// successRate returns the share of the recent lookups that succeeded, or -1 without lookups
+func (h providerHealth) successRate() float64 {
+	if len(h.recent) == 0 {
+		return -1
+	}
+	succeeded := 0
+	for _, lookup := range h.recent {
+		if lookup.ok {
+			succeeded++
+		}
+	}
+	return float64(succeeded) / float64(len(h.recent))
+}
+
+// averageLatency returns the mean duration of the recent lookups
+func (h providerHealth) averageLatency() time.Duration {
+	if len(h.recent) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, lookup := range h.recent {
+		total += lookup.latency
+	}
+	return total / time.Duration(len(h.recent))
+}
+
+// availableProviders drops the providers whose circuit breaker is open, unless that would leave none
+func availableProviders(config Configuration, providers []ipProvider) []ipProvider {
+	providerHealthMutex.Lock()
+	defer providerHealthMutex.Unlock()
+
+	var available []ipProvider
+	for _, provider := range providers {
+		if health, ok := providerHealthStats[provider.URL]; ok && health.breakerState() == BreakerOpen {
+			debugf(config, "Skipping %s, its circuit breaker is open until %s", provider.URL, displayTime(config, health.OpenUntil).Format(time.RFC3339))
+			continue
+		}
+		available = append(available, provider)
+	}
+	if len(available) == 0 {
+		// Trying a failing provider beats not trying at all
+		debugf(config, "Every provider has an open circuit breaker, trying them anyway")
+		return providers
+	}
+	return available
+}
+
+// providerHealthSnapshot returns a copy of the health of every provider used so far
+func providerHealthSnapshot() map[string]providerHealth {
+	providerHealthMutex.Lock()
+	defer providerHealthMutex.Unlock()
+
+	snapshot := make(map[string]providerHealth, len(providerHealthStats))
+	for provider, health := range providerHealthStats {
+		h := *health
+		h.recent = append([]providerLookup(nil), health.recent...)
+		snapshot[provider] = h
+	}
+	return snapshot
+}
+
+// providerStats is the entry of a provider in the GET /api/providers response
+type providerStats struct {
+	Provider            string    `json:"provider"`
+	Name                string    `json:"name"`
+	Configured          bool      `json:"configured"`
+	Successes           int       `json:"successes"`
+	Failures            int       `json:"failures"`
+	RecentLookups       int       `json:"recent_lookups"`
+	SuccessRate         *float64  `json:"success_rate"`
+	AverageLatencyMS    int64     `json:"average_latency_ms"`
+	LastSuccess         time.Time `json:"last_success,omitzero"`
+	LastFailure         time.Time `json:"last_failure,omitzero"`
+	LastError           string    `json:"last_error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	CircuitBreaker      string    `json:"circuit_breaker"`
+	OpenUntil           time.Time `json:"open_until,omitzero"`
+}
+
+// providerStatsList returns the statistics of the configured providers in the order they are tried,
+// followed by providers that are no longer configured but were used since the start
+func providerStatsList(config Configuration) []providerStats {
+	health := providerHealthSnapshot()
+
+	stats := func(url, name string, configured bool) providerStats {
+		h := health[url]
+		entry := providerStats{
+			Provider:            url,
+			Name:                name,
+			Configured:          configured,
+			Successes:           h.Successes,
+			Failures:            h.Failures,
+			RecentLookups:       len(h.recent),
+			AverageLatencyMS:    h.averageLatency().Milliseconds(),
+			LastSuccess:         displayTime(config, h.LastSuccess),
+			LastFailure:         displayTime(config, h.LastFailure),
+			LastError:           h.LastError,
+			ConsecutiveFailures: h.ConsecutiveFailures,
+			CircuitBreaker:      h.breakerState(),
+		}
+		if rate := h.successRate(); rate >= 0 {
+			entry.SuccessRate = &rate
+		}
+		if entry.CircuitBreaker == BreakerOpen {
+			entry.OpenUntil = displayTime(config, h.OpenUntil)
+		}
+		return entry
+	}
+
+	var list []providerStats
+	seen := make(map[string]bool)
+	for _, provider := range providersFor(config) {
+		if seen[provider.URL] {
+			continue
+		}
+		seen[provider.URL] = true
+		list = append(list, stats(provider.URL, provider.name(), true))
+	}
+
+	var others []string
+	for url := range health {
+		if !seen[url] {
+			others = append(others, url)
+		}
+	}
+	sort.Strings(others)
+	for _, url := range others {
+		list = append(list, stats(url, ipProvider{URL: url}.name(), false))
+	}
+	return list
+}
+
+// handleProviders serves GET /api/providers, the observed behavior of every IP provider
+func handleProviders(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"window":    providerWindowSize,
+		"providers": providerStatsList(*activeConfig.Load()),
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// publishedIP is the IP an Access Group was last seen holding, after a lookup or an update
+type publishedIP struct {
+	IP         string    `json:"ip"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// recentlyVerified reports whether the group held the IP when it was last verified, within VERIFY_INTERVAL
+func recentlyVerified(config Configuration, ip string) (time.Time, bool) {
+	if config.VerifyInterval <= 0 {
+		return time.Time{}, false
+	}
+
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		log.Printf("Failed to read the last published IP, looking up the Access Group: %v", err)
+		return time.Time{}, false
+	}
+	published, ok := state.PublishedIPs[config.RuleID]
+	if !ok || published.IP != ip || time.Since(published.VerifiedAt) >= config.VerifyInterval {
+		return time.Time{}, false
+	}
+	return published.VerifiedAt, true
+}
+
+// rememberPublishedIP records that the group was just seen holding the IP
+func rememberPublishedIP(config Configuration, ip string) {
+	if config.VerifyInterval <= 0 || config.DryRun {
+		return
+	}
+
+	err := updateState(config.StateFile, func(state *State) error {
+		if state.PublishedIPs == nil {
+			state.PublishedIPs = map[string]publishedIP{}
+		}
+		state.PublishedIPs[config.RuleID] = publishedIP{IP: ip, VerifiedAt: time.Now()}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to remember the published IP: %v", err)
+	}
+}
+
+// forgetPublishedIPs makes the next check look up the given groups, or all of them, e.g. after they were
+// changed by something other than a check
+func forgetPublishedIPs(config Configuration, ruleIDs ...string) {
+	if config.VerifyInterval <= 0 {
+		return
+	}
+
+	err := updateState(config.StateFile, func(state *State) error {
+		if len(ruleIDs) == 0 {
+			state.PublishedIPs = nil
+		}
+		for _, ruleID := range ruleIDs {
+			delete(state.PublishedIPs, ruleID)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to forget the published IP: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// recentErrorsLimit is the number of errors kept for /ready
+const recentErrorsLimit = 10
+
+// Error categories reported in /ready
+const (
+	ErrorCategoryPreflight    = "preflight"
+	ErrorCategoryIPDetection  = "ip_detection"
+	ErrorCategoryCloudflare   = "cloudflare"
+	ErrorCategoryNotification = "notification"
+	ErrorCategoryState        = "state"
+)
+
+// recordedError is an error kept for /ready
+type recordedError struct {
+	Timestamp time.Time `json:"timestamp"`
+	Category  string    `json:"category"`
+	Message   string    `json:"message"`
+}
+
+var (
+	recentErrorsMutex sync.Mutex
+	recentErrors      []recordedError
+)
+
+// recordError keeps an error for /ready, dropping the oldest beyond recentErrorsLimit
+func recordError(category string, err error) {
+	recentErrorsMutex.Lock()
+	defer recentErrorsMutex.Unlock()
+
+	recentErrors = append(recentErrors, recordedError{Timestamp: time.Now(), Category: category, Message: err.Error()})
+	if len(recentErrors) > recentErrorsLimit {
+		recentErrors = recentErrors[len(recentErrors)-recentErrorsLimit:]
+	}
+}
+
+// recentErrorsSnapshot returns the recorded errors, newest first, with timestamps in DISPLAY_TZ
+func recentErrorsSnapshot(config Configuration) []recordedError {
+	recentErrorsMutex.Lock()
+	defer recentErrorsMutex.Unlock()
+
+	snapshot := make([]recordedError, len(recentErrors))
+	for i, recorded := range recentErrors {
+		recorded.Timestamp = displayTime(config, recorded.Timestamp)
+		snapshot[len(recentErrors)-1-i] = recorded
+	}
+	return snapshot
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Actions reported in a RunResult
+const (
+	ActionNoChange = "no_change"
+	ActionUpdated  = "updated"
+	ActionSkipped  = "skipped"
+	ActionPending  = "pending"  // a new IP awaits confirmation
+	ActionFlapping = "flapping" // the detected IP oscillates, the group is held
+	ActionError    = "error"
+)
+
+// Exit codes used in --once mode
+const (
+	ExitNoChange = 0
+	ExitError    = 1
+	ExitUpdated  = 2
+)
+
+// RunResult describes the outcome of a single check run
+type RunResult struct {
+	NewIP      string        `json:"new_ip,omitempty"` // the detected public IP
+	PreviousIP string        `json:"previous_ip,omitempty"`
+	Action     string        `json:"action"`
+	DryRun     bool          `json:"dry_run"`
+	Provider   string        `json:"provider,omitempty"` // the IP provider that answered
+	Duration   time.Duration `json:"-"`
+	Errors     []string      `json:"errors,omitempty"`
+
+	// Targets has the outcome for each Access Group when RULEID lists several
+	Targets []TargetResult `json:"targets,omitempty"`
+
+	// DetectionTime and UpdateTime break down Duration into IP detection and the Cloudflare update
+	DetectionTime time.Duration `json:"-"`
+	UpdateTime    time.Duration `json:"-"`
+}
+
+// fail records an error and marks the run as failed
+func (r *RunResult) fail(category string, err error) {
+	r.Action = ActionError
+	r.Errors = append(r.Errors, err.Error())
+	recordError(category, err)
+}
+
+// ExitCode maps the run outcome to the --once mode exit code
+func (r RunResult) ExitCode() int {
+	switch r.Action {
+	case ActionUpdated:
+		return ExitUpdated
+	case ActionError:
+		return ExitError
+	default:
+		return ExitNoChange
+	}
+}
+
+// MarshalJSON adds the durations in milliseconds
+func (r RunResult) MarshalJSON() ([]byte, error) {
+	type plain RunResult
+	return json.Marshal(struct {
+		plain
+		DurationMS      int64 `json:"duration_ms"`
+		DetectionTimeMS int64 `json:"detection_ms,omitempty"`
+		UpdateTimeMS    int64 `json:"update_ms,omitempty"`
+	}{plain(r), r.Duration.Milliseconds(), r.DetectionTime.Milliseconds(), r.UpdateTime.Milliseconds()})
+}
+
+// withTimings appends how the IP was detected and how long the run took to a notification, if enabled
+func withTimings(config Configuration, message string, result RunResult) string {
+	if !config.NotifyTimings {
+		return message
+	}
+	return message + "\n" + tr(config, "Detected via %s in %s, Cloudflare update took %s",
+		result.Provider, result.DetectionTime.Round(time.Millisecond), result.UpdateTime.Round(time.Millisecond))
+}
+
+// newResultPrinter returns a function writing --once results to stdout in the requested format:
+// text (nothing beyond the logs), json, or template=<Go template> executed against the RunResult
+func newResultPrinter(output string) (func(RunResult) error, error) {
+	if text, ok := strings.CutPrefix(output, "template="); ok {
+		tmpl, err := template.New("output").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output template: %w", err)
+		}
+
+		return func(result RunResult) error {
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, result); err != nil {
+				return err
+			}
+			if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+				buf.WriteByte('\n')
+			}
+			_, err := os.Stdout.Write(buf.Bytes())
+			return err
+		}, nil
+	}
+
+	switch output {
+	case "", "text":
+		return func(RunResult) error { return nil }, nil
+	case "json":
+		return func(result RunResult) error {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(result)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported output format %q (expected text, json or template=...)", output)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// startHealthCheckServer starts a simple HTTP server for container health checks
+func startHealthCheckServer(config Configuration, port string) {
+	// Check if the port is empty
+	if port == "" {
+		port = "8080"
+	}
+
+	mux := http.NewServeMux()
+
+	// Define a simple handler for health checks. It always answers 200 while the process runs, so an
+	// outage of a provider or Cloudflare doesn't make the orchestrator restart the container.
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		health := componentsHealth(*activeConfig.Load())
+		lines := []string{strings.ToUpper(overallStatus(health))}
+		for _, component := range components {
+			lines = append(lines, fmt.Sprintf("%s: %s", component, health[component].Status))
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(strings.Join(lines, "\n") + "\n"))
+		if err != nil {
+			return
+		}
+	})
+
+	// Define a handler for readiness checks that provides more details
+	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		current := *activeConfig.Load()
+		info := map[string]interface{}{
+			"status":        "OK",
+			"timestamp":     displayTime(current, time.Now()).Format(time.RFC3339),
+			"uptime":        time.Since(startTime).String(),
+			"recent_errors": recentErrorsSnapshot(current),
+		}
+
+		// Confirm the deployed instance runs the intended release and configuration
+		info["build"] = currentBuildInfo()
+		info["config_fingerprint"] = current.Fingerprint
+		info["features"] = enabledFeatures(current)
+
+		// Explain a degraded updater to probes and humans without log access
+		if run := lastRunResult.Load(); run != nil {
+			lastRunInfo := map[string]interface{}{
+				"timestamp": displayTime(current, run.At).Format(time.RFC3339),
+				"action":    run.Result.Action,
+			}
+			// Detection and update latency, to spot slow or flaky providers early
+			if run.Result.Provider != "" {
+				lastRunInfo["provider"] = run.Result.Provider
+				lastRunInfo["detection_ms"] = run.Result.DetectionTime.Milliseconds()
+				lastRunInfo["update_ms"] = run.Result.UpdateTime.Milliseconds()
+			}
+			info["last_run"] = lastRunInfo
+			if run.Result.Action == ActionError {
+				info["status"] = "DEGRADED"
+			}
+		}
+		if updatedAt := lastUpdateAt.Load(); updatedAt != nil {
+			info["last_successful_update"] = displayTime(current, *updatedAt).Format(time.RFC3339)
+		} else if c := persistedCounters.Load(); c != nil && !c.LastUpdate.IsZero() {
+			// Updated before the last restart
+			info["last_successful_update"] = displayTime(current, c.LastUpdate).Format(time.RFC3339)
+		}
+		if counters := countersInfo(current); counters != nil {
+			info["counters"] = counters
+		}
+
+		// Show which part is broken, a degraded component degrades the whole updater
+		health := componentsHealth(current)
+		info["components"] = health
+		if overallStatus(health) != ComponentOK {
+			info["status"] = "DEGRADED"
+		}
+
+		jsonData, err := json.Marshal(info)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write(jsonData)
+		if err != nil {
+			return
+		}
+	})
+
+	// Per-provider statistics, to tune the provider list from this vantage point
+	mux.HandleFunc("GET /api/providers", handleProviders)
+
+	// Control endpoints, only enabled when an API token is configured
+	if config.APIToken != "" {
+		mux.Handle("POST /api/set-ip", requireAPIToken(config.APIToken, http.HandlerFunc(handleSetIP)))
+		mux.Handle("POST /api/token", requireAPIToken(config.APIToken, http.HandlerFunc(handleRotateToken)))
+	}
+
+	// Cloudflare notifications trigger a drift check, only enabled when a webhook secret is configured
+	if config.WebhookSecret != "" {
+		mux.Handle("POST /webhooks/cloudflare", handleCloudflareWebhook(config.WebhookSecret))
+	}
+
+	// Serve the OpenAPI description of these endpoints
+	mux.HandleFunc("/api/openapi.json", handleOpenAPI)
+
+	// Protect the server against abuse: per-client rate limiting, body size caps and timeouts
+	limiter := newClientRateLimiter(config.HTTPRateLimit, config.HTTPRateBurst, config.HTTPTrustProxyHeaders)
+	handler := limiter.middleware(limitRequestBody(config.HTTPMaxBodyBytes, mux))
+
+	// Allow browser dashboards hosted on other origins to call the endpoints
+	handler = corsMiddleware(config.CORSAllowedOrigins, config.CORSAllowedMethods, config.CORSAllowedHeaders, handler)
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%s", port),
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		MaxHeaderBytes:    16 << 10,
+	}
+
+	// Start the HTTP server in a goroutine
+	go func() {
+		log.Printf("Starting health check server on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("Health check server error: %v", err)
+		}
+	}()
+}
+
+// clientRateLimiter applies a token bucket per client IP
+type clientRateLimiter struct {
+	mu          sync.Mutex
+	rate        float64 // tokens per second, 0 disables limiting
+	burst       float64
+	trustProxy  bool
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+}
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+func newClientRateLimiter(rate float64, burst int, trustProxy bool) *clientRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &clientRateLimiter{
+		rate:        rate,
+		burst:       float64(burst),
+		trustProxy:  trustProxy,
+		buckets:     make(map[string]*tokenBucket),
+		lastCleanup: time.Now(),
+	}
+}
+
+// allow takes a token from the client's bucket, refilling it according to the elapsed time
+func (l *clientRateLimiter) allow(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	// Forget clients that have been idle long enough for their bucket to be full again
+	if now.Sub(l.lastCleanup) > time.Minute {
+		for key, bucket := range l.buckets {
+			if now.Sub(bucket.lastSeen).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastCleanup = now
+	}
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.buckets[client] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.lastSeen).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.lastSeen = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// middleware rejects clients exceeding their rate with 429 Too Many Requests
+func (l *clientRateLimiter) middleware(next http.Handler) http.Handler {
+	if l.rate <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := clientIP(r, l.trustProxy)
+		if !l.allow(client) {
+			log.Printf("Rate limit exceeded for %s on %s", client, r.URL.Path)
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the requesting client's IP, optionally trusting proxy headers
+// (e.g. when the server is exposed through a Cloudflare Tunnel or reverse proxy).
+// Only the rightmost X-Forwarded-For entry is used: it is appended by the trusted
+// proxy, while the ones before it are sent by the client.
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if ip := strings.TrimSpace(r.Header.Get("CF-Connecting-IP")); ip != "" {
+			return ip
+		}
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			last := forwarded[len(forwarded)-1]
+			if i := strings.LastIndex(last, ","); i >= 0 {
+				last = last[i+1:]
+			}
+			if ip := strings.TrimSpace(last); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitRequestBody caps the size of request bodies
+func limitRequestBody(maxBytes int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBytes {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsMiddleware adds CORS headers for the allowed origins and answers preflight requests.
+// With no allowed origins configured, no CORS headers are sent.
+func corsMiddleware(origins, methods, headers []string, next http.Handler) http.Handler {
+	if len(origins) == 0 {
+		return next
+	}
+
+	allowAll := slices.Contains(origins, "*")
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(headers, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if !allowAll && !slices.Contains(origins, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if allowAll {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+
+		// Preflight request
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAPIToken only lets requests through that carry the configured bearer token
+func requireAPIToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(jsonData)
+	if err != nil {
+		return
+	}
+}
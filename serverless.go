@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lambdaRuntimeAPIVersion is the version of the AWS Lambda runtime API used for custom runtimes
+const lambdaRuntimeAPIVersion = "2018-06-01"
+
+// serverlessMinRunTime is the least time left before the invocation deadline a check is started with
+const serverlessMinRunTime = 3 * time.Second
+
+// serverlessEvent is the part of an invocation event we use: an IP pushed by the monitored site, either
+// directly or in the body of a Lambda function URL or API Gateway request
+type serverlessEvent struct {
+	IP              string            `json:"ip"`
+	Body            *string           `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+	Headers         map[string]string `json:"headers"`
+}
+
+// runServerless implements the serverless subcommand: a single check per invocation, as an AWS Lambda
+// custom runtime when AWS_LAMBDA_RUNTIME_API is set, or else as an HTTP function on PORT, e.g. on Google
+// Cloud Run functions. A scheduler such as EventBridge or Cloud Scheduler invokes it instead of CRON.
+func runServerless(args []string) {
+	flags := flag.NewFlagSet("serverless", flag.ExitOnError)
+	profile := flags.String("profile", "", "Named configuration profile to use")
+	_ = flags.Parse(args)
+
+	configureLogging(strings.ToLower(os.Getenv("LOG_FORMAT")), nil)
+	log.Printf("Cloudflare Access Group IP Updater %s, serverless mode", currentBuildInfo())
+
+	// Only the temporary directory is writable on serverless platforms
+	if os.Getenv("STATE_FILE") == "" {
+		os.Setenv("STATE_FILE", filepath.Join(os.TempDir(), "cf-ip-updater-state.json"))
+	}
+	backend := initConfigSources(*profile)
+
+	if api := os.Getenv("AWS_LAMBDA_RUNTIME_API"); api != "" {
+		runLambda(api, backend)
+		return
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			IP string `json:"ip"`
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 64*1024))
+		if err == nil && len(bytes.TrimSpace(body)) > 0 {
+			err = json.Unmarshal(body, &request)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body: " + err.Error()})
+			return
+		}
+
+		result, err := serverlessCheck(backend, r.Header.Get("Authorization"), request.IP, time.Time{}, false)
+		switch {
+		case errors.Is(err, ErrUnauthorized):
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case result.Action == ActionSkipped:
+			// Another invocation is checking, the caller must retry so a pushed IP isn't lost
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterRunningCheck(*activeConfig.Load())))
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "a check is running, retry later"})
+		case result.Action == ActionError:
+			// Let the scheduler see and retry the failure
+			writeJSON(w, http.StatusInternalServerError, result)
+		default:
+			writeJSON(w, http.StatusOK, result)
+		}
+	})
+
+	log.Printf("Waiting for invocations on :%s", port)
+	log.Fatal(http.ListenAndServe(":"+port, mux))
+}
+
+// runLambda processes invocations from the AWS Lambda runtime API until the function is shut down
+func runLambda(api string, backend *configBackend) {
+	base := fmt.Sprintf("http://%s/%s/runtime/invocation/", api, lambdaRuntimeAPIVersion)
+	// Waiting for the next invocation blocks for as long as the function is idle
+	client := &http.Client{}
+
+	for {
+		resp, err := client.Get(base + "next")
+		if err != nil {
+			log.Fatalf("Failed to get the next Lambda invocation: %v", err)
+		}
+		event, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			log.Fatalf("Failed to read the Lambda invocation: %v", err)
+		}
+		requestID := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+		var deadline time.Time
+		if ms, err := strconv.ParseInt(resp.Header.Get("Lambda-Runtime-Deadline-Ms"), 10, 64); err == nil {
+			deadline = time.UnixMilli(ms)
+		}
+
+		result, err := handleLambdaEvent(backend, event, deadline)
+		if err == nil && result.Action == ActionError {
+			err = errors.New(strings.Join(result.Errors, "; "))
+		}
+
+		// A failed invocation shows up in the Lambda error metrics and the retries of asynchronous invocations
+		path, payload := base+requestID+"/response", interface{}(result)
+		if err != nil {
+			path, payload = base+requestID+"/error", map[string]string{"errorMessage": err.Error(), "errorType": "CheckFailed"}
+		}
+		data, _ := json.Marshal(payload)
+		resp, err = client.Post(path, "application/json", bytes.NewReader(data))
+		if err != nil {
+			log.Fatalf("Failed to report the Lambda invocation result: %v", err)
+		}
+		resp.Body.Close()
+	}
+}
+
+// handleLambdaEvent runs a check for a Lambda invocation: a scheduled event, a direct invocation with
+// {"ip": "..."}, or a function URL or API Gateway request with that body
+func handleLambdaEvent(backend *configBackend, payload []byte, deadline time.Time) (RunResult, error) {
+	var event serverlessEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return RunResult{}, validationErrorf("invalid invocation event: %v", err)
+	}
+
+	// Direct invocations are authorized by IAM, HTTP requests by API_TOKEN
+	if event.Body == nil {
+		return serverlessCheck(backend, "", event.IP, deadline, true)
+	}
+	body := []byte(*event.Body)
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(*event.Body)
+		if err != nil {
+			return RunResult{}, validationErrorf("invalid request body: %v", err)
+		}
+		body = decoded
+	}
+	var request struct {
+		IP string `json:"ip"`
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &request); err != nil {
+			return RunResult{}, validationErrorf("invalid request body: %v", err)
+		}
+	}
+	authorization := ""
+	for name, value := range event.Headers {
+		if strings.EqualFold(name, "Authorization") {
+			authorization = value
+		}
+	}
+	return serverlessCheck(backend, authorization, request.IP, deadline, false)
+}
+
+// serverlessCheck runs one check, with the IP pushed by the monitored site if any. The function's own
+// egress IP says nothing about the site, so without a pushed IP the site's gateway (UNIFI_URL) or
+// device (TAILSCALE_DEVICE) is asked. Untrusted invocations must present API_TOKEN.
+func serverlessCheck(backend *configBackend, authorization, pushedIP string, deadline time.Time, trusted bool) (RunResult, error) {
+	// Apply changes made in the backend since the previous invocation
+	if backend != nil {
+		if err := loadConfigBackend(backend); err != nil {
+			log.Printf("Keeping the previous configuration: %v", err)
+		}
+	}
+	config, err := loadConfig("CRON")
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	if !trusted {
+		provided, ok := strings.CutPrefix(authorization, "Bearer ")
+		switch {
+		case config.APIToken != "" && (!ok || subtle.ConstantTimeCompare([]byte(provided), []byte(config.APIToken)) != 1):
+			return RunResult{}, ErrUnauthorized
+		case config.APIToken == "" && pushedIP != "":
+			return RunResult{}, fmt.Errorf("%w: pushing an IP over HTTP requires API_TOKEN", ErrUnauthorized)
+		}
+	}
+
+	switch {
+	case pushedIP != "" && net.ParseIP(pushedIP) == nil:
+		return RunResult{}, validationErrorf("invalid pushed IP %q", pushedIP)
+	case pushedIP == "" && config.UniFiURL == "" && config.TailscaleDevice == "":
+		return RunResult{}, validationErrorf("no IP source for the monitored site: push the IP with the invocation, or set UNIFI_URL or TAILSCALE_DEVICE")
+	case config.MultiWAN || config.IPv6PrefixInterface != "" || config.ConfirmBy == ConfirmByProviders:
+		return RunResult{}, validationErrorf("MULTI_WAN, IPV6_PREFIX_INTERFACE and CONFIRM_BY=providers are not supported in serverless mode")
+	}
+
+	// Finish before the platform ends the invocation, rather than starting a check that can only time out
+	if !deadline.IsZero() {
+		remaining := time.Until(deadline) - time.Second
+		if remaining < serverlessMinRunTime {
+			return RunResult{}, fmt.Errorf("the invocation deadline is too close to run a check (%s left), raise the function timeout", time.Until(deadline).Round(time.Millisecond))
+		}
+		config.RunTimeout = min(config.RunTimeout, remaining)
+	}
+
+	// Only the first invocation looks the account up, later ones use the cached one
+	if err := resolveConfigAccount(&config); err != nil {
+		return RunResult{}, err
+	}
+	config.Serverless = true
+	config.PushedIP = pushedIP
+	configureLogging(config.LogFormat, config.DisplayLocation)
+	activeConfig.Store(&config)
+
+	return checkAndUpdateIP(config), nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Counters of the current session, summarized when the updater stops
+var (
+	sessionChecks  atomic.Int64
+	sessionUpdates atomic.Int64
+	sessionLastIP  atomic.Pointer[string]
+)
+
+// recordSessionRun counts a completed check run, skipped runs did not check anything
+func recordSessionRun(result RunResult) {
+	if result.Action == ActionSkipped {
+		return
+	}
+
+	sessionChecks.Add(1)
+	if result.Action == ActionUpdated {
+		sessionUpdates.Add(1)
+	}
+	if result.NewIP != "" {
+		sessionLastIP.Store(&result.NewIP)
+	}
+}
+
+// sessionSummary describes the session for the shutdown notification, so a restart is informative rather than noise
+func sessionSummary(config Configuration) string {
+	lines := []string{
+		tr(config, "Uptime: %s", time.Since(startTime).Round(time.Second)),
+		tr(config, "Checks: %d, updates: %d", sessionChecks.Load(), sessionUpdates.Load()),
+	}
+	if ip := sessionLastIP.Load(); ip != nil {
+		lines = append(lines, tr(config, "Last IP: %s", *ip))
+	}
+	if errors := recentErrorsSnapshot(config); len(errors) > 0 {
+		lines = append(lines, tr(config, "Last error (%s): %s", errors[0].Timestamp.Format(time.RFC3339), errors[0].Message))
+	}
+	return strings.Join(lines, "\n")
+}
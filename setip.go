@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// manualHoldUntil is the Unix time (in nanoseconds) until which automatic updates are paused
+// after an IP was set manually through the API
+var manualHoldUntil atomic.Int64
+
+// manualHoldActive reports whether a manual IP override is currently pausing automatic updates
+func manualHoldActive() (time.Time, bool) {
+	until := time.Unix(0, manualHoldUntil.Load())
+	return until, time.Now().Before(until)
+}
+
+// setIP writes a specific IP to the Access Group, bypassing detection
+func setIP(ctx context.Context, config Configuration, ip string) error {
+	if net.ParseIP(ip) == nil {
+		return validationErrorf("%q is not a valid IP address", ip)
+	}
+	// A single IP would replace the IPs of every uplink
+	if config.MultiWAN {
+		return validationErrorf("setting the IP manually is not supported with MULTI_WAN")
+	}
+
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Manually setting Cloudflare Access Group IP to %s", ip)
+	err = targetErrors(config, forEachTarget(config, func(target Configuration) error {
+		// Keep the rest of the group declared in the spec
+		if target.GroupSpecFile != "" {
+			return putGroupSpecIP(ctx, target, ip, state.StaticIPs)
+		}
+		_, err := updateCloudflareGroup(ctx, target, ip)
+		return err
+	}))
+	if err != nil {
+		return err
+	}
+
+	if config.NotificationURL != "" {
+		if err := sendNotification(config, SeverityInfo, tr(config, "✋ IP manually set in Cloudflare Access Group: %s", ip)); err != nil {
+			log.Printf("Failed to send notification: %v", err)
+		}
+	}
+	return nil
+}
+
+// runSetIP implements the set-ip subcommand
+func runSetIP(args []string) {
+	flags := flag.NewFlagSet("set-ip", flag.ExitOnError)
+	profile := flags.String("profile", "", "Named configuration profile to use")
+	dryRun := flags.Bool("dry-run", false, "Log the change without applying it")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cloudflare-access-group-ip-updater set-ip [--profile name] [--dry-run] <ip>")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		log.Fatal("set-ip requires exactly one IP address")
+	}
+
+	initConfigSources(*profile)
+	config, err := loadCommandConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *dryRun {
+		config.DryRun = true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.RunTimeout)
+	defer cancel()
+
+	if err := setIP(ctx, config, flags.Arg(0)); err != nil {
+		log.Fatalf("Failed to set IP: %v", err)
+	}
+
+	log.Printf("Cloudflare Access Group updated with IP: %s", flags.Arg(0))
+	log.Println("Note: a running updater will replace it on its next check; use the API with \"hold\" to pause it")
+}
+
+// handleSetIP implements POST /api/set-ip
+func handleSetIP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		IP   string `json:"ip"`
+		Hold string `json:"hold"` // optional duration during which automatic updates are paused
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body: " + err.Error()})
+		return
+	}
+
+	var hold time.Duration
+	if request.Hold != "" {
+		var err error
+		hold, err = time.ParseDuration(request.Hold)
+		if err != nil || hold < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid hold duration %q", request.Hold)})
+			return
+		}
+	}
+
+	config := *activeConfig.Load()
+	ctx, cancel := context.WithTimeout(r.Context(), config.RunTimeout)
+	defer cancel()
+
+	// Don't race a scheduled check, nor hold the request for as long as it runs
+	if !runMutex.TryLock() {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterRunningCheck(config)))
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "a check is running, retry later"})
+		return
+	}
+	defer runMutex.Unlock()
+
+	if err := setIP(ctx, config, request.IP); err != nil {
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, ErrValidation):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrRateLimited):
+			status = http.StatusTooManyRequests
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	response := map[string]interface{}{"ip": request.IP, "dry_run": config.DryRun}
+	if hold > 0 {
+		until := time.Now().Add(hold)
+		manualHoldUntil.Store(until.UnixNano())
+		response["hold_until"] = displayTime(config, until).Format(time.RFC3339)
+		log.Printf("Automatic updates paused until %s", displayTime(config, until).Format(time.RFC3339))
+	} else {
+		manualHoldUntil.Store(0)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// retryAfterRunningCheck estimates the seconds until the running check is over, at most RUN_TIMEOUT
+func retryAfterRunningCheck(config Configuration) int {
+	remaining := 5 * time.Second
+	if started := checkStartedAt.Load(); started != nil {
+		remaining = config.RunTimeout - time.Since(*started)
+	}
+	return max(int(remaining.Seconds()+0.999), 1)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// groupDetails is the full Access Group as returned by Cloudflare, keeping every rule type
+type groupDetails struct {
+	Result struct {
+		ID        string                   `json:"id"`
+		Name      string                   `json:"name"`
+		Include   []map[string]interface{} `json:"include"`
+		Require   []map[string]interface{} `json:"require"`
+		Exclude   []map[string]interface{} `json:"exclude"`
+		CreatedAt string                   `json:"created_at"`
+		UpdatedAt string                   `json:"updated_at"`
+	} `json:"result"`
+}
+
+// formatRule renders an Access rule such as {"ip": {"ip": "192.0.2.1/32"}} as "ip 192.0.2.1/32"
+func formatRule(rule map[string]interface{}) string {
+	kinds := make([]string, 0, len(rule))
+	for kind := range rule {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		value := rule[kind]
+		// Most rules hold a single field, show its value directly
+		if fields, ok := value.(map[string]interface{}); ok && len(fields) == 1 {
+			for _, field := range fields {
+				value = field
+			}
+		}
+		if text, ok := value.(string); ok {
+			parts = append(parts, kind+" "+text)
+			continue
+		}
+		encoded, _ := json.Marshal(value)
+		parts = append(parts, kind+" "+string(encoded))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printGroup pretty-prints the Access Group, marking the entries managed by the updater: the first
+// include entry, or with MULTI_WAN every IP entry, holds a detected IP
+func printGroup(w io.Writer, group groupDetails, staticIPs []string, multiWAN bool) {
+	fmt.Fprintf(w, "Group:   %s (%s)\n", group.Result.Name, group.Result.ID)
+	fmt.Fprintf(w, "Created: %s\n", group.Result.CreatedAt)
+	fmt.Fprintf(w, "Updated: %s\n", group.Result.UpdatedAt)
+
+	sections := []struct {
+		title string
+		rules []map[string]interface{}
+	}{
+		{"Include", group.Result.Include},
+		{"Require", group.Result.Require},
+		{"Exclude", group.Result.Exclude},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(w, "\n%s:\n", section.title)
+		if len(section.rules) == 0 {
+			fmt.Fprintln(w, "  (none)")
+			continue
+		}
+
+		for i, rule := range section.rules {
+			line := fmt.Sprintf("  %d. %s", i+1, formatRule(rule))
+			if section.title == "Include" {
+				if ip, ok := rule["ip"].(map[string]interface{}); ok {
+					cidr, _ := ip["ip"].(string)
+					switch {
+					case slices.Contains(staticIPs, cidr):
+						line += "  [managed: static]"
+					case multiWAN:
+						line += "  [managed: WAN IP]"
+					case i == 0:
+						line += "  [managed: dynamic IP]"
+					}
+				}
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// runShowGroup implements the show-group subcommand
+func runShowGroup(args []string) {
+	flags := flag.NewFlagSet("show-group", flag.ExitOnError)
+	profile := flags.String("profile", "", "Named configuration profile to use")
+	ruleID := flags.String("group", "", "Access Group rule ID when RULEID lists several (default: the first)")
+	raw := flags.Bool("json", false, "Print the raw Cloudflare API response")
+	_ = flags.Parse(args)
+
+	initConfigSources(*profile)
+	config, err := loadCommandConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *ruleID != "" {
+		config.RuleID = *ruleID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.RunTimeout)
+	defer cancel()
+
+	body, err := fetchCloudflareGroup(ctx, config)
+	if err != nil {
+		log.Fatalf("Error getting Cloudflare Access Group: %v", err)
+	}
+
+	if *raw {
+		var pretty interface{}
+		if err := json.Unmarshal(body, &pretty); err != nil {
+			log.Fatal(err)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(pretty); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var group groupDetails
+	if err := json.Unmarshal(body, &group); err != nil {
+		log.Fatal(err)
+	}
+
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		log.Printf("Ignoring state file: %v", err)
+	}
+
+	printGroup(os.Stdout, group, state.StaticIPs, config.MultiWAN)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// State is the data the updater keeps between runs in STATE_FILE
+type State struct {
+	// StaticIPs are additional include entries managed with the static command, kept next to the dynamic IP
+	StaticIPs []string `json:"static_ips,omitempty"`
+
+	// WANIPs are the last known IPs of each uplink in multi-WAN mode, WAN1 first
+	WANIPs []string `json:"wan_ips,omitempty"`
+
+	// PendingIPs are new IPs of each Access Group awaiting CONFIRMATIONS, by RULEID
+	PendingIPs map[string]pendingIP `json:"pending_ips,omitempty"`
+
+	// DetectedIPs are the recent changes of the detected IP, and FlappingSince is set while it flaps
+	DetectedIPs   []detectedIP `json:"detected_ips,omitempty"`
+	FlappingSince time.Time    `json:"flapping_since,omitzero"`
+
+	// PublishedIPs are the IPs each Access Group was last seen holding, by RULEID, used with VERIFY_INTERVAL
+	PublishedIPs map[string]publishedIP `json:"published_ips,omitempty"`
+
+	// Counters are the cumulative run statistics, reported by /ready
+	Counters *counters `json:"counters,omitempty"`
+}
+
+// stateMutex serializes read-modify-write cycles of the state file within the process
+var stateMutex sync.Mutex
+
+// loadState reads the state file, returning an empty state when it doesn't exist yet
+func loadState(path string) (State, error) {
+	var state State
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// saveState writes the state file atomically so a crash never leaves it half written
+func saveState(path string, state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// updateState applies change to the stored state and saves it
+func updateState(path string, change func(*State) error) error {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	state, err := loadState(path)
+	if err != nil {
+		return err
+	}
+	if err := change(&state); err != nil {
+		return err
+	}
+	return saveState(path, state)
+}
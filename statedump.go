@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// lastRun is the most recent completed check run
+type lastRun struct {
+	At     time.Time
+	Result RunResult
+}
+
+var lastRunResult atomic.Pointer[lastRun]
+
+// lastUpdateAt is when the Access Group was last updated successfully
+var lastUpdateAt atomic.Pointer[time.Time]
+
+// handleDumpSignal dumps the internal state to the log whenever SIGUSR2 is received
+func handleDumpSignal(c *cron.Cron) {
+	signals := make(chan os.Signal, 1)
+	if !notifyDumpSignal(signals) {
+		return
+	}
+
+	for range signals {
+		log.Printf("State dump:\n%s", stateDump(c))
+	}
+}
+
+// stateDump describes the internal state of the updater for live debugging
+func stateDump(c *cron.Cron) string {
+	var b strings.Builder
+	config := *activeConfig.Load()
+
+	fmt.Fprintf(&b, "Uptime: %s\n", time.Since(startTime).Round(time.Second))
+	// Only non-sensitive settings, credentials may hide in any URL or string of the configuration
+	fmt.Fprintln(&b, "Configuration:")
+	fmt.Fprintf(&b, "  fingerprint: %s, features: %s\n", config.Fingerprint, strings.Join(enabledFeatures(config), ", "))
+	fmt.Fprintf(&b, "  account: %s, access groups: %s\n", config.AccountID, strings.Join(config.RuleIDs, ", "))
+	fmt.Fprintf(&b, "  schedule: %s, run timeout: %s, dry run: %t\n", config.CronSchedule, config.RunTimeout, config.DryRun)
+	fmt.Fprintf(&b, "  state file: %s, log level: %s\n", config.StateFile, config.LogLevel)
+	if usingSecondaryToken(config.AuthToken) {
+		fmt.Fprintln(&b, "Cloudflare token: failed over to AUTH_TOKEN_SECONDARY")
+	}
+
+	fmt.Fprintln(&b, "Last run:")
+	if run := lastRunResult.Load(); run != nil {
+		fmt.Fprintf(&b, "  at %s: action=%s new_ip=%s previous_ip=%s duration=%s",
+			displayTime(config, run.At).Format(time.RFC3339), run.Result.Action, run.Result.NewIP, run.Result.PreviousIP, run.Result.Duration)
+		if len(run.Result.Errors) > 0 {
+			fmt.Fprintf(&b, " errors=%q", run.Result.Errors)
+		}
+		fmt.Fprintln(&b)
+	} else {
+		fmt.Fprintln(&b, "  none yet")
+	}
+
+	fmt.Fprintln(&b, "Pending:")
+	running := !runMutex.TryLock()
+	if !running {
+		runMutex.Unlock()
+	}
+	fmt.Fprintf(&b, "  check running: %t\n", running)
+	fmt.Fprintf(&b, "  pre-flight retry scheduled: %t\n", preflightRetryPending.Load())
+	if until, ok := manualHoldActive(); ok {
+		fmt.Fprintf(&b, "  manual hold until: %s\n", displayTime(config, until).Format(time.RFC3339))
+	}
+
+	fmt.Fprintln(&b, "Scheduler:")
+	for _, entry := range c.Entries() {
+		fmt.Fprintf(&b, "  entry %d: next run %s, previous run %s\n", entry.ID, formatTime(config, entry.Next), formatTime(config, entry.Prev))
+	}
+
+	fmt.Fprintln(&b, "Provider health:")
+	health := providerHealthSnapshot()
+	if len(health) == 0 {
+		fmt.Fprintln(&b, "  no lookups yet")
+	}
+	providers := make([]string, 0, len(health))
+	for provider := range health {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		h := health[provider]
+		fmt.Fprintf(&b, "  %s: %d ok, %d failed, last success %s", provider, h.Successes, h.Failures, formatTime(config, h.LastSuccess))
+		if h.LastError != "" {
+			fmt.Fprintf(&b, ", last error at %s: %s", formatTime(config, h.LastFailure), h.LastError)
+		}
+		if state := h.breakerState(); state != BreakerClosed {
+			fmt.Fprintf(&b, ", circuit breaker %s", state)
+		}
+		fmt.Fprintln(&b)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// formatTime formats a timestamp for the state dump, "never" for the zero time
+func formatTime(config Configuration, t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return displayTime(config, t).Format(time.RFC3339)
+}
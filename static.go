@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"slices"
+)
+
+// normalizeCIDR turns an IP or CIDR into the canonical CIDR form used in the Access Group
+func normalizeCIDR(value string) (string, error) {
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return network.String(), nil
+	}
+	if ip := net.ParseIP(value); ip != nil {
+		return ipToCIDR(ip.String()), nil
+	}
+	return "", validationErrorf("%q is not a valid IP address or CIDR", value)
+}
+
+// syncStaticIPs pushes a new list of static IPs to every target Access Group
+func syncStaticIPs(ctx context.Context, config Configuration, previous, staticIPs []string) error {
+	return targetErrors(config, forEachTarget(config, func(target Configuration) error {
+		return syncGroupStaticIPs(ctx, target, previous, staticIPs)
+	}))
+}
+
+// syncGroupStaticIPs pushes a new list of static IPs to the Access Group, keeping the dynamic IPs
+// (every other entry, such as the IPs of all uplinks with MULTI_WAN) in front of them
+func syncGroupStaticIPs(ctx context.Context, config Configuration, previous, staticIPs []string) error {
+	if config.GroupSpecFile != "" {
+		return syncGroupSpecStaticIPs(ctx, config, staticIPs)
+	}
+
+	cfGroup, err := getCloudflareGroup(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	var cidrs []string
+	for _, entry := range cfGroup.Result.Include {
+		cidr := entry.IP.IP
+		if cidr != "" && !slices.Contains(previous, cidr) && !slices.Contains(staticIPs, cidr) && !slices.Contains(cidrs, cidr) {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	cidrs = append(cidrs, staticIPs...)
+
+	updated, err := putCloudflareGroupIPs(ctx, config, cidrs)
+	if err != nil {
+		return err
+	}
+	logGroupDiff(config, cfGroup.Raw, updated)
+	return nil
+}
+
+// runStatic implements the static subcommand managing additional non-dynamic include entries
+func runStatic(args []string) {
+	flags := flag.NewFlagSet("static", flag.ExitOnError)
+	profile := flags.String("profile", "", "Named configuration profile to use")
+	dryRun := flags.Bool("dry-run", false, "Log the change without applying it")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cloudflare-access-group-ip-updater static [--profile name] [--dry-run] add|remove <ip or cidr>")
+		fmt.Fprintln(flags.Output(), "       cloudflare-access-group-ip-updater static [--profile name] list")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	initConfigSources(*profile)
+	config, err := loadCommandConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *dryRun {
+		config.DryRun = true
+	}
+
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	command := flags.Arg(0)
+	if command == "list" {
+		for _, cidr := range state.StaticIPs {
+			fmt.Println(cidr)
+		}
+		return
+	}
+
+	if (command != "add" && command != "remove") || flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cidr, err := normalizeCIDR(flags.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	staticIPs := slices.Clone(state.StaticIPs)
+	switch {
+	case command == "add" && slices.Contains(staticIPs, cidr):
+		log.Printf("%s is already a static IP", cidr)
+		return
+	case command == "add":
+		staticIPs = append(staticIPs, cidr)
+	case !slices.Contains(staticIPs, cidr):
+		log.Fatalf("%s is not a static IP", cidr)
+	default:
+		staticIPs = slices.DeleteFunc(staticIPs, func(entry string) bool { return entry == cidr })
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.RunTimeout)
+	defer cancel()
+
+	if err := syncStaticIPs(ctx, config, state.StaticIPs, staticIPs); err != nil {
+		log.Fatalf("Failed to update Cloudflare Access Group: %v", err)
+	}
+	if config.DryRun {
+		return
+	}
+
+	// Only record the change once Cloudflare accepted it, so the state matches the group
+	err = updateState(config.StateFile, func(state *State) error {
+		state.StaticIPs = staticIPs
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if command == "add" {
+		log.Printf("Added static IP %s to Cloudflare Access Group", cidr)
+	} else {
+		log.Printf("Removed static IP %s from Cloudflare Access Group", cidr)
+	}
+
+	if config.NotificationURL != "" {
+		message := tr(config, "📌 Static IP %s added to Cloudflare Access Group", cidr)
+		if command == "remove" {
+			message = tr(config, "📌 Static IP %s removed from Cloudflare Access Group", cidr)
+		}
+		if err := sendNotification(config, SeverityInfo, message); err != nil {
+			log.Printf("Failed to send notification: %v", err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// tailscaleDevice is the part of a Tailscale API device we use
+type tailscaleDevice struct {
+	ID                 string `json:"id"`
+	NodeID             string `json:"nodeId"`
+	Name               string `json:"name"`
+	Hostname           string `json:"hostname"`
+	ClientConnectivity struct {
+		Endpoints []string `json:"endpoints"`
+	} `json:"clientConnectivity"`
+}
+
+// tailscaleProvider returns the provider reading the public endpoint of a tailnet device
+func tailscaleProvider(config Configuration) ipProvider {
+	return ipProvider{
+		URL:  fmt.Sprintf("%s (Tailscale device %s)", config.TailscaleAPIURL, config.TailscaleDevice),
+		Name: "Tailscale",
+		Fetch: func(ctx context.Context) (string, error) {
+			return fetchTailscaleDeviceIP(ctx, config)
+		},
+	}
+}
+
+// fetchTailscaleDeviceIP returns the public IP the device was last seen connecting from
+func fetchTailscaleDeviceIP(ctx context.Context, config Configuration) (string, error) {
+	client := newHTTPClient(config, config.ProviderTimeout)
+
+	var devices []tailscaleDevice
+	if looksLikeTailscaleID(config.TailscaleDevice) {
+		endpoint := fmt.Sprintf("%s/api/v2/device/%s?fields=all", config.TailscaleAPIURL, url.PathEscape(config.TailscaleDevice))
+		body, err := doTailscaleRequest(ctx, client, config, endpoint)
+		if err != nil {
+			return "", err
+		}
+		var device tailscaleDevice
+		if err := json.Unmarshal(body, &device); err != nil {
+			return "", fmt.Errorf("invalid Tailscale device: %w", err)
+		}
+		devices = []tailscaleDevice{device}
+	} else {
+		// A hostname or MagicDNS name, find it in the device list
+		endpoint := fmt.Sprintf("%s/api/v2/tailnet/%s/devices?fields=all", config.TailscaleAPIURL, url.PathEscape(config.TailscaleTailnet))
+		body, err := doTailscaleRequest(ctx, client, config, endpoint)
+		if err != nil {
+			return "", err
+		}
+		var response struct {
+			Devices []tailscaleDevice `json:"devices"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return "", fmt.Errorf("invalid Tailscale device list: %w", err)
+		}
+		devices = response.Devices
+	}
+
+	for _, device := range devices {
+		if !device.matches(config.TailscaleDevice) {
+			continue
+		}
+
+		// Endpoints list every address the node can be reached on, the public ones are what it connects from
+		var ipv6 string
+		for _, endpoint := range device.ClientConnectivity.Endpoints {
+			host, _, err := net.SplitHostPort(endpoint)
+			if err != nil {
+				continue
+			}
+			ip := net.ParseIP(host)
+			if !isPublicIP(ip) {
+				continue
+			}
+			if ip.To4() != nil {
+				return ip.String(), nil
+			}
+			if ipv6 == "" {
+				ipv6 = ip.String()
+			}
+		}
+		if ipv6 != "" {
+			return ipv6, nil
+		}
+		return "", fmt.Errorf("tailscale device %s has no public endpoint, is it online?", config.TailscaleDevice)
+	}
+
+	return "", fmt.Errorf("tailscale device %s not found", config.TailscaleDevice)
+}
+
+// matches reports whether the device is the one configured by ID, hostname or MagicDNS name
+func (d tailscaleDevice) matches(device string) bool {
+	return device == d.ID || device == d.NodeID || strings.EqualFold(device, d.Hostname) ||
+		strings.EqualFold(device, d.Name) || strings.EqualFold(device, strings.SplitN(d.Name, ".", 2)[0])
+}
+
+// looksLikeTailscaleID reports whether the value has the form of a device ID rather than a hostname
+func looksLikeTailscaleID(value string) bool {
+	if strings.HasPrefix(value, "n") && strings.HasSuffix(value, "CNTRL") {
+		return true
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
+
+func doTailscaleRequest(ctx context.Context, client *http.Client, config Configuration, endpoint string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+config.TailscaleAPIKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Printf("Failed to close response body: %v", err)
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Message: fmt.Sprintf("tailscale API returned status %d: %s", resp.StatusCode, string(body)), StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return body, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TargetResult is the outcome of a run for one Access Group when RULEID lists several
+type TargetResult struct {
+	RuleID     string   `json:"rule_id"`
+	Action     string   `json:"action"`
+	PreviousIP string   `json:"previous_ip,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// forEachTarget calls fn for every target Access Group with a pool of TARGET_PARALLELISM workers,
+// returning the results in the order of RULEID. The config passed to fn has RuleID set to the target.
+func forEachTarget[T any](config Configuration, fn func(target Configuration) T) []T {
+	if len(config.RuleIDs) <= 1 {
+		return []T{fn(config)}
+	}
+
+	results := make([]T, len(config.RuleIDs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(max(config.TargetParallelism, 1), len(config.RuleIDs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				target := config
+				target.RuleID = config.RuleIDs[i]
+				// Tell the notifications of the groups apart
+				target.NotificationIdentifier = strings.TrimSpace(fmt.Sprintf("%s [%s]", config.NotificationIdentifier, target.RuleID))
+				results[i] = fn(target)
+			}
+		}()
+	}
+	for i := range config.RuleIDs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// targetErrors combines the errors returned by forEachTarget, naming the group when RULEID lists several
+func targetErrors(config Configuration, errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Errorf("access group %s: %w", config.RuleIDs[i], err))
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// runTargets applies update to every target Access Group, each starting from a copy of result,
+// and aggregates the outcomes into a single result and their notifications into a single message
+func runTargets(config Configuration, result RunResult, update func(target Configuration, result *RunResult)) RunResult {
+	start := time.Now()
+	batch := &notificationBatch{}
+	results := forEachTarget(config, func(target Configuration) RunResult {
+		if len(config.RuleIDs) > 1 {
+			target.notifications = batch
+		}
+		targetResult := result
+		update(target, &targetResult)
+		return targetResult
+	})
+	if len(results) == 1 {
+		return results[0]
+	}
+
+	// The run failed if any group failed, updated if any group changed, and pending if a group awaits confirmation
+	result.Action = ActionNoChange
+	for i, targetResult := range results {
+		ruleID := config.RuleIDs[i]
+		log.Printf("Access Group %s: %s", ruleID, targetResult.Action)
+
+		result.Targets = append(result.Targets, TargetResult{
+			RuleID:     ruleID,
+			Action:     targetResult.Action,
+			PreviousIP: targetResult.PreviousIP,
+			Errors:     targetResult.Errors,
+		})
+		for _, err := range targetResult.Errors {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", ruleID, err))
+		}
+
+		switch {
+		case targetResult.Action == ActionError:
+			result.Action = ActionError
+		case targetResult.Action == ActionUpdated && result.Action != ActionError:
+			result.Action = ActionUpdated
+		case targetResult.Action == ActionPending && result.Action == ActionNoChange:
+			result.Action = ActionPending
+		}
+		if result.PreviousIP == "" {
+			result.PreviousIP = targetResult.PreviousIP
+		}
+	}
+	result.UpdateTime = time.Since(start)
+	batch.send(config, result.Targets)
+
+	return result
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"sync/atomic"
+)
+
+// failedOverToken is the primary token that was rejected by Cloudflare, whose requests now use AUTH_TOKEN_SECONDARY
+var failedOverToken atomic.Pointer[string]
+
+// failoverStatus is the status of the rejection that caused a failover not notified yet, 0 if none
+var failoverStatus atomic.Int64
+
+// cloudflareAuthErrorCodes are the Cloudflare error codes of a 403 meaning the token itself was rejected,
+// rather than lacking a permission: invalid API token, unknown credentials and authentication error
+var cloudflareAuthErrorCodes = []int{1000, 9103, 10000}
+
+// tokenFailoverTransport retries a request rejected with an authentication error using the secondary token,
+// and sends every later request with it, so a revoked or expired primary token doesn't stop the updates
+type tokenFailoverTransport struct {
+	next      http.RoundTripper
+	primary   string
+	secondary string
+}
+
+func (t *tokenFailoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if usingSecondaryToken(t.primary) {
+		return t.next.RoundTrip(t.withToken(req, t.secondary))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || !tokenRejected(resp) || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+
+	retry := t.withToken(req, t.secondary)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	retryResp, err := t.next.RoundTrip(retry)
+	if err != nil || isAuthError(retryResp.StatusCode) {
+		// Both tokens were rejected, e.g. for a missing permission, report the original error
+		if err == nil {
+			retryResp.Body.Close()
+		}
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	if previous := failedOverToken.Swap(&t.primary); previous == nil || *previous != t.primary {
+		log.Printf("The primary Cloudflare API token was rejected with status %d, failed over to AUTH_TOKEN_SECONDARY", resp.StatusCode)
+		// Notified by notifyTokenFailover, with the configuration of the run rather than of this request
+		failoverStatus.Store(int64(resp.StatusCode))
+	}
+	return retryResp, nil
+}
+
+// tokenRejected reports whether Cloudflare rejected the token itself: a 401, or a 403 with an authentication
+// error code. Other 403s mean the token lacks a permission, which the secondary token won't fix for good.
+func tokenRejected(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return true
+	case http.StatusForbidden:
+	default:
+		return false
+	}
+
+	// Keep the body readable for the caller
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+
+	var body struct {
+		Errors []struct {
+			Code int `json:"code"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(data, &body) != nil {
+		return false
+	}
+	for _, e := range body.Errors {
+		if slices.Contains(cloudflareAuthErrorCodes, e.Code) {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyTokenFailover sends the notification of a failover that happened since the last call
+func notifyTokenFailover(config Configuration) {
+	status := failoverStatus.Swap(0)
+	if status == 0 || config.NotificationURL == "" {
+		return
+	}
+	if err := sendNotification(config, SeverityWarning, tr(config, "⚠️ The primary Cloudflare API token was rejected (status %d), failed over to the secondary token", status)); err != nil {
+		log.Printf("Failed to send notification: %v", err)
+	}
+}
+
+// withToken returns a copy of the request authenticated with the token
+func (t *tokenFailoverTransport) withToken(req *http.Request, token string) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Header.Set("Authorization", "Bearer "+token)
+	return clone
+}
+
+// usingSecondaryToken reports whether requests made with the primary token go out with the secondary one,
+// which ends once the primary token is replaced, e.g. by a rotation or a restart
+func usingSecondaryToken(primary string) bool {
+	failed := failedOverToken.Load()
+	return failed != nil && *failed == primary
+}
+
+// isAuthError reports whether Cloudflare rejected the credentials of a request
+func isAuthError(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
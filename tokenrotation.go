@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// authTokenFileInterval is how often AUTH_TOKEN_FILE is checked for a new token
+const authTokenFileInterval = 10 * time.Second
+
+// authTokenOverride is a token rotated at runtime without AUTH_TOKEN_FILE, kept by configuration reloads
+var (
+	authTokenMutex    sync.Mutex
+	authTokenOverride string
+)
+
+// validateAuthToken checks that the token is active and can read every target Access Group
+func validateAuthToken(ctx context.Context, config Configuration, token string) error {
+	// Failing over to the secondary token would hide a bad new one
+	config.AuthToken, config.AuthTokenSecondary = token, ""
+	if err := verifyCloudflareToken(ctx, config); err != nil {
+		return fmt.Errorf("%w: the new token was rejected: %w", ErrValidation, err)
+	}
+	err := targetErrors(config, forEachTarget(config, func(target Configuration) error {
+		_, err := fetchCloudflareGroup(ctx, target)
+		return err
+	}))
+	if err != nil {
+		return fmt.Errorf("%w: the new token can't read the Access Group: %w", ErrValidation, err)
+	}
+	return nil
+}
+
+// rotateAuthToken validates a new Cloudflare API token and makes it the active one, without a restart
+func rotateAuthToken(token string, source string) error {
+	// Reloads read the token through rotatedAuthToken, so configMutex is always taken first
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	authTokenMutex.Lock()
+	defer authTokenMutex.Unlock()
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return validationErrorf("the new token is empty")
+	}
+	config := *activeConfig.Load()
+	if token == config.AuthToken {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.RunTimeout)
+	defer cancel()
+	if err := validateAuthToken(ctx, config, token); err != nil {
+		return err
+	}
+
+	// Keep the file in sync, so a restart doesn't bring back the old token
+	switch {
+	case config.AuthTokenFile == "":
+		authTokenOverride = token
+	case source != "AUTH_TOKEN_FILE":
+		if err := writeAuthTokenFile(config.AuthTokenFile, token); err != nil {
+			log.Printf("Warning: failed to write the new token to AUTH_TOKEN_FILE, a restart will use the old one: %v", err)
+			authTokenOverride = token
+		}
+	}
+
+	config.AuthToken = token
+	activeConfig.Store(&config)
+	log.Printf("Cloudflare API token rotated (%s)", source)
+	if config.NotificationURL != "" {
+		if err := sendNotification(config, SeverityInfo, tr(config, "🔑 Cloudflare API token rotated")); err != nil {
+			log.Printf("Failed to send notification: %v", err)
+		}
+	}
+	return nil
+}
+
+// rotatedAuthToken returns the token rotated through the API, "" if none
+func rotatedAuthToken() string {
+	authTokenMutex.Lock()
+	defer authTokenMutex.Unlock()
+	return authTokenOverride
+}
+
+// readAuthTokenFile reads the token from AUTH_TOKEN_FILE
+func readAuthTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return token, nil
+}
+
+// writeAuthTokenFile replaces the token file atomically, readable by the owner only
+func writeAuthTokenFile(path, token string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(token + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// watchAuthTokenFile rotates the token whenever AUTH_TOKEN_FILE holds a new one, e.g. after a secret
+// manager replaced it. A token failing validation is reported once and the current one is kept.
+func watchAuthTokenFile(path string) {
+	var rejected string
+	for range time.Tick(authTokenFileInterval) {
+		token, err := readAuthTokenFile(path)
+		if err != nil || token == activeConfig.Load().AuthToken || token == rejected {
+			continue
+		}
+
+		log.Printf("New Cloudflare API token found in %s", path)
+		if err := rotateAuthToken(token, "AUTH_TOKEN_FILE"); err != nil {
+			rejected = token
+			log.Printf("Error rotating the Cloudflare API token, keeping the current one: %v", err)
+			config := *activeConfig.Load()
+			if config.NotificationURL != "" {
+				if err := sendNotification(config, SeverityError, tr(config, "❌ Error rotating the Cloudflare API token: %v", err)); err != nil {
+					log.Printf("Failed to send notification: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// handleRotateToken implements POST /api/token
+func handleRotateToken(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body: " + err.Error()})
+		return
+	}
+
+	if err := rotateAuthToken(request.Token, "API"); err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, ErrValidation) {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "rotated"})
+}
+
+// runRotateToken implements the rotate-token subcommand: it validates the token read from standard input,
+// then writes it to AUTH_TOKEN_FILE or hands it to the running updater through its API
+func runRotateToken(args []string) {
+	flags := flag.NewFlagSet("rotate-token", flag.ExitOnError)
+	profile := flags.String("profile", "", "Named configuration profile to use")
+	serverURL := flags.String("url", "http://localhost:8080", "Address of the running updater, used without AUTH_TOKEN_FILE")
+	_ = flags.Parse(args)
+
+	initConfigSources(*profile)
+	config, err := loadCommandConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Read from stdin rather than the command line, which ends up in the shell history
+	fmt.Fprintln(os.Stderr, "New Cloudflare API token:")
+	token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Fatal(err)
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		log.Fatal("No token given")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.RunTimeout)
+	defer cancel()
+	if err := validateAuthToken(ctx, config, token); err != nil {
+		log.Fatal(err)
+	}
+
+	if config.AuthTokenFile != "" {
+		if err := writeAuthTokenFile(config.AuthTokenFile, token); err != nil {
+			log.Fatalf("Failed to write %s: %v", config.AuthTokenFile, err)
+		}
+		log.Printf("Token written to %s, a running updater picks it up within %s", config.AuthTokenFile, authTokenFileInterval)
+		return
+	}
+
+	if config.APIToken == "" {
+		log.Fatal("Without AUTH_TOKEN_FILE the token is handed to the running updater through its API, which requires API_TOKEN")
+	}
+	payload, _ := json.Marshal(map[string]string{"token": token})
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(*serverURL, "/")+"/api/token", bytes.NewReader(payload))
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+config.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := newHTTPClient(config, config.RunTimeout).Do(req)
+	if err != nil {
+		log.Fatalf("Failed to reach the running updater: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("The running updater rejected the token: %s", strings.TrimSpace(string(body)))
+	}
+	log.Println("Token rotated in the running updater. Update AUTH_TOKEN as well, or the next restart uses the old one")
+}
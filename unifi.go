@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"sort"
+	"strings"
+)
+
+// unifiProvider returns the provider reading the WAN address from a UniFi controller or UniFi OS console
+func unifiProvider(config Configuration) ipProvider {
+	return ipProvider{
+		URL:  config.UniFiURL,
+		Name: "UniFi",
+		Fetch: func(ctx context.Context) (string, error) {
+			ips, err := fetchUniFiWANIPs(ctx, config)
+			if err != nil {
+				return "", err
+			}
+			return primaryWANIP(ips), nil
+		},
+	}
+}
+
+// newUniFiClient creates the HTTP client for the controller, keeping the session cookie between requests.
+// Consoles usually have a self-signed certificate, hence the optional verification skip.
+func newUniFiClient(config Configuration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.UniFiSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	client := wrapHTTPClient(config, config.ProviderTimeout, transport)
+	client.Jar, _ = cookiejar.New(nil)
+	return client
+}
+
+// fetchUniFiWANIPs returns the public address of each gateway WAN interface, WAN1 first,
+// with "" for interfaces that are down or have no public address
+func fetchUniFiWANIPs(ctx context.Context, config Configuration) ([]string, error) {
+	client := newUniFiClient(config)
+
+	// UniFi OS consoles (UDM, UCG, Cloud Key Gen2+) proxy the Network application under /proxy/network
+	apiPrefix := config.UniFiURL + "/proxy/network"
+	headers := http.Header{}
+
+	if config.UniFiAPIKey != "" {
+		headers.Set("X-API-KEY", config.UniFiAPIKey)
+	} else {
+		csrfToken, legacy, err := unifiLogin(ctx, client, config)
+		if err != nil {
+			return nil, err
+		}
+		if legacy {
+			apiPrefix = config.UniFiURL
+		}
+		if csrfToken != "" {
+			headers.Set("X-CSRF-Token", csrfToken)
+		}
+	}
+
+	url := fmt.Sprintf("%s/api/s/%s/stat/device", apiPrefix, config.UniFiSite)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = headers
+
+	body, err := doUniFiRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data []map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("invalid UniFi device list: %w", err)
+	}
+
+	var ips []string
+	for _, device := range response.Data {
+		// Gateways report their uplinks as wan1, wan2, ...
+		var wanKeys []string
+		for key := range device {
+			if strings.HasPrefix(key, "wan") && len(key) == 4 {
+				wanKeys = append(wanKeys, key)
+			}
+		}
+		sort.Strings(wanKeys)
+
+		for _, key := range wanKeys {
+			var wan struct {
+				IP string `json:"ip"`
+				Up bool   `json:"up"`
+			}
+			if err := json.Unmarshal(device[key], &wan); err != nil || !wan.Up {
+				ips = append(ips, "")
+				continue
+			}
+			if !isPublicIP(net.ParseIP(wan.IP)) {
+				log.Printf("Ignoring non-public UniFi %s address %s", strings.ToUpper(key), wan.IP)
+				ips = append(ips, "")
+				continue
+			}
+			ips = append(ips, wan.IP)
+		}
+	}
+
+	if primaryWANIP(ips) == "" {
+		return nil, fmt.Errorf("no public WAN address reported by the UniFi gateway")
+	}
+	return ips, nil
+}
+
+// isPublicIP reports whether the IP is routable on the internet, i.e. usable in the Access Group
+func isPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || !ip.IsGlobalUnicast() {
+		return false
+	}
+	// Carrier-grade NAT range 100.64.0.0/10
+	ip4 := ip.To4()
+	return ip4 == nil || ip4[0] != 100 || ip4[1]&0xc0 != 64
+}
+
+// unifiLogin opens a session with username and password, first as a UniFi OS console,
+// then as a standalone Network controller. It returns the CSRF token to send with API calls.
+func unifiLogin(ctx context.Context, client *http.Client, config Configuration) (csrfToken string, legacy bool, err error) {
+	payload, err := json.Marshal(map[string]string{
+		"username": config.UniFiUsername,
+		"password": config.UniFiPassword,
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, path := range []string{"/api/auth/login", "/api/login"} {
+		req, err := http.NewRequestWithContext(ctx, "POST", config.UniFiURL+path, bytes.NewReader(payload))
+		if err != nil {
+			return "", false, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", false, err
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return "", false, &StatusError{Message: fmt.Sprintf("UniFi login failed with status %d", resp.StatusCode), StatusCode: resp.StatusCode}
+		}
+		return resp.Header.Get("X-CSRF-Token"), path == "/api/login", nil
+	}
+
+	return "", false, fmt.Errorf("UniFi login endpoint not found at %s", config.UniFiURL)
+}
+
+func doUniFiRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Printf("Failed to close response body: %v", err)
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Message: fmt.Sprintf("UniFi API returned status %d: %s", resp.StatusCode, string(body)), StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return body, nil
+}
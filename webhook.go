@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// webhookCheckDelay lets a burst of dashboard edits settle into a single drift check
+const webhookCheckDelay = 5 * time.Second
+
+// webhookCheckPending is set while a drift check triggered by a webhook is scheduled
+var webhookCheckPending atomic.Bool
+
+// cloudflareWebhook is the part of a Cloudflare notification webhook payload we log
+type cloudflareWebhook struct {
+	Name      string `json:"name"`
+	Text      string `json:"text"`
+	AlertType string `json:"alert_type"`
+}
+
+// handleCloudflareWebhook serves POST /webhooks/cloudflare: Cloudflare notifications, such as Access
+// policy change alerts, trigger an immediate drift check instead of waiting for the schedule
+func handleCloudflareWebhook(secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Cloudflare sends the secret of the webhook destination in cf-webhook-auth
+		provided := r.Header.Get("cf-webhook-auth")
+		if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		var webhook cloudflareWebhook
+		if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		log.Printf("Cloudflare notification received: %s %s", webhook.Name, webhook.AlertType)
+		if webhook.Text != "" {
+			debugf(*activeConfig.Load(), "Cloudflare notification text: %s", webhook.Text)
+		}
+
+		scheduleWebhookCheck()
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "drift check scheduled"})
+	}
+}
+
+// scheduleWebhookCheck runs a check shortly, coalescing webhooks that arrive before it starts
+func scheduleWebhookCheck() {
+	if !webhookCheckPending.CompareAndSwap(false, true) {
+		return
+	}
+
+	log.Printf("Running a drift check in %s", webhookCheckDelay)
+	time.AfterFunc(webhookCheckDelay, func() {
+		webhookCheckPending.Store(false)
+		// The notification is about a change made elsewhere, so look the groups up
+		config := *activeConfig.Load()
+		forgetPublishedIPs(config)
+		// Try again once a check that is still running finished, rather than losing the notification
+		result := checkAndUpdateIP(config)
+		if _, held := manualHoldActive(); result.Action == ActionSkipped && !held {
+			scheduleWebhookCheck()
+		}
+	})
+}